@@ -0,0 +1,89 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BMCBudgetSpec defines the desired state of BMCBudget
+type BMCBudgetSpec struct {
+	// Budget for the estimated cost accrued by the servers in the namespace, in USD.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +kubebuilder:validation:Required
+	Amount string `json:"amount"`
+
+	// What happens when the budget is exceeded. Defaults to Warn.
+	// +kubebuilder:validation:Optional
+	Action BudgetAction `json:"action,omitempty"`
+}
+
+// BudgetAction describes the reaction to an exceeded budget.
+// Only one of the following actions may be specified.
+// If none of the following actions are specified, the default one is Warn.
+// +kubebuilder:validation:Enum=Warn;Block
+type BudgetAction string
+
+const (
+	// BudgetWarn emits warning events on the budget and on new servers.
+	BudgetWarn BudgetAction = `Warn`
+	// BudgetBlock additionally rejects new servers in the namespace.
+	BudgetBlock BudgetAction = `Block`
+)
+
+// BMCBudgetStatus defines the observed state of BMCBudget
+type BMCBudgetStatus struct {
+	// Estimated cost accrued by the servers in the namespace since the budget
+	// was created, including servers that have since been deleted, in USD.
+	Spent string `json:"spent,omitempty"`
+	// Last estimated cost of each existing server by UID, in USD.
+	Servers map[string]string `json:"servers,omitempty"`
+	// Estimated cost accrued by deleted or replaced servers, in USD.
+	Retired string `json:"retired,omitempty"`
+	// Combined hourly rate of the servers in the namespace, in USD.
+	HourlyRate string `json:"hourlyRate,omitempty"`
+	// Whether the spent amount exceeds the budget.
+	Exceeded bool `json:"exceeded"`
+}
+
+// +kubebuilder:object:root=true
+
+// BMCBudget is the Schema for the bmcbudgets API
+// +kubebuilder:printcolumn:name="Amount",type=string,JSONPath=`.spec.amount`
+// +kubebuilder:printcolumn:name="Spent",type=string,JSONPath=`.status.spent`
+// +kubebuilder:printcolumn:name="Action",type=string,JSONPath=`.spec.action`
+// +kubebuilder:printcolumn:name="Exceeded",type=boolean,JSONPath=`.status.exceeded`
+type BMCBudget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BMCBudgetSpec   `json:"spec,omitempty"`
+	Status BMCBudgetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BMCBudgetList contains a list of BMCBudget
+type BMCBudgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BMCBudget `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BMCBudget{}, &BMCBudgetList{})
+}
@@ -0,0 +1,88 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BMCClusterSpec defines the desired state of BMCCluster
+type BMCClusterSpec struct {
+	// Endpoint used to communicate with the control plane.
+	// +kubebuilder:validation:Optional
+	ControlPlaneEndpoint APIEndpoint `json:"controlPlaneEndpoint"`
+
+	// Location ID where machines of the cluster are created. Reported to Cluster API as the failure domain.
+	// +kubebuilder:validation:Optional
+	Location LocationID `json:"location,omitempty"`
+}
+
+// APIEndpoint represents a reachable Kubernetes API endpoint.
+type APIEndpoint struct {
+	// The hostname on which the API server is serving.
+	Host string `json:"host"`
+
+	// The port on which the API server is serving.
+	Port int32 `json:"port"`
+}
+
+// IsZero returns true if both host and port are zero values.
+func (v APIEndpoint) IsZero() bool {
+	return v.Host == "" && v.Port == 0
+}
+
+// FailureDomainSpec is the Cluster API failure domain description.
+type FailureDomainSpec struct {
+	// Whether the failure domain is suitable for control plane machines.
+	ControlPlane bool `json:"controlPlane,omitempty"`
+
+	// Attributes of the failure domain.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// BMCClusterStatus defines the observed state of BMCCluster
+type BMCClusterStatus struct {
+	Ready          bool                         `json:"ready"`
+	FailureDomains map[string]FailureDomainSpec `json:"failureDomains,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BMCCluster is the Schema for the bmcclusters API. It implements the Cluster API infrastructure cluster contract.
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.metadata.labels.cluster\.x-k8s\.io/cluster-name`
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.spec.controlPlaneEndpoint.host`
+type BMCCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BMCClusterSpec   `json:"spec,omitempty"`
+	Status BMCClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BMCClusterList contains a list of BMCCluster
+type BMCClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BMCCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BMCCluster{}, &BMCClusterList{})
+}
@@ -0,0 +1,109 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BMCMachineSpec defines the desired state of BMCMachine
+type BMCMachineSpec struct {
+	// Provider ID of the machine in the form bmc://<server_id>. Set by the controller.
+	// +kubebuilder:validation:Optional
+	ProviderID *string `json:"providerID,omitempty"`
+
+	// Name of the ServerClass whose values are used for any field not set on this machine.
+	// +kubebuilder:validation:Optional
+	ServerClassName string `json:"serverClassName,omitempty"`
+
+	// OS ID used for server creation.
+	// +kubebuilder:validation:Optional
+	OS ServerOS `json:"os,omitempty"`
+
+	// Server type used for creation.
+	// +kubebuilder:validation:Optional
+	Type ServerType `json:"type,omitempty"`
+
+	// Location ID where the server is created.
+	// +kubebuilder:validation:Optional
+	Location LocationID `json:"location,omitempty"`
+
+	// Whether or not to install SSH Keys marked as default in addition to any SSH keys specified on this resource.
+	// +kubebuilder:validation:Optional
+	InstallDefaultSSHKeys *bool `json:"installDefaultSshKeys,omitempty"`
+
+	// A list of SSH key IDs (BMC resource ID) that will be installed on the server.
+	// +kubebuilder:validation:Optional
+	SSHKeyIDs []string `json:"sshKeyIds,omitempty"`
+
+	// The type of networks where this server should be attached.
+	// +kubebuilder:validation:Optional
+	NetworkType NetworkType `json:"networkType,omitempty"`
+}
+
+// MachineAddressType describes a valid MachineAddress type.
+type MachineAddressType string
+
+const (
+	MachineHostName   MachineAddressType = `Hostname`
+	MachineExternalIP MachineAddressType = `ExternalIP`
+	MachineInternalIP MachineAddressType = `InternalIP`
+)
+
+// MachineAddress contains information for the machine's address.
+type MachineAddress struct {
+	Type    MachineAddressType `json:"type"`
+	Address string             `json:"address"`
+}
+
+// BMCMachineStatus defines the observed state of BMCMachine
+type BMCMachineStatus struct {
+	Ready          bool             `json:"ready"`
+	ServerName     string           `json:"serverName,omitempty"`
+	BMCStatus      string           `json:"status,omitempty"`
+	Addresses      []MachineAddress `json:"addresses,omitempty"`
+	FailureReason  *string          `json:"failureReason,omitempty"`
+	FailureMessage *string          `json:"failureMessage,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BMCMachine is the Schema for the bmcmachines API. It implements the Cluster API infrastructure machine contract.
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.metadata.labels.cluster\.x-k8s\.io/cluster-name`
+// +kubebuilder:printcolumn:name="ProviderID",type=string,JSONPath=`.spec.providerID`
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+type BMCMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BMCMachineSpec   `json:"spec,omitempty"`
+	Status BMCMachineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BMCMachineList contains a list of BMCMachine
+type BMCMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BMCMachine `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BMCMachine{}, &BMCMachineList{})
+}
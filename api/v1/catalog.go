@@ -0,0 +1,134 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// ServerCatalog describes the server products offered by BMC.
+// +kubebuilder:object:generate=false
+type ServerCatalog interface {
+	// Offered reports whether a server type is offered in a location and
+	// whether the catalog lists the server type at all.
+	Offered(t ServerType, l LocationID) (offered bool, known bool)
+	// KnownLocation reports whether the catalog lists the location.
+	KnownLocation(l LocationID) bool
+}
+
+// Catalog is consulted when servers are validated. It defaults to
+// StaticCatalog and is replaced by the manager with a catalog backed by the
+// BMC products API.
+var Catalog ServerCatalog = StaticCatalog
+
+// StaticCatalog lists the server products known when this release was built.
+// It is used whenever the BMC products API cannot be reached.
+var StaticCatalog ServerCatalog = staticCatalog{}
+
+var (
+	knownLocations = []LocationID{Phoenix, Ashburn, Singapore, Amsterdam}
+
+	knownServerTypes = []ServerType{
+		S1C1Small, S1C1Medium, S1C2Medium, S1C2Large,
+		D1C1Small, D1C2Small, D1C3Small, D1C4Small,
+		D1C1Medium, D1C2Medium, D1C3Medium, D1C4Medium,
+		D1C1Large, D1C2Large, D1C3Large, D1C4Large,
+		D1M1Medium, D1M2Medium, D1M3Medium, D1M4Medium,
+	}
+
+	// serverTypeLocations lists the locations offering server types that are not
+	// available everywhere. Other known types are offered in every location.
+	serverTypeLocations = map[ServerType][]LocationID{
+		D1M1Medium: {Phoenix, Ashburn},
+		D1M2Medium: {Phoenix, Ashburn},
+		D1M3Medium: {Phoenix, Ashburn},
+		D1M4Medium: {Phoenix, Ashburn},
+	}
+
+	knownOSs = []ServerOS{UbuntuBionic, CentosCentos7}
+
+	// serverTypeOSs lists the OS images supported by server types that do not
+	// support every image. Other types support every known image.
+	serverTypeOSs = map[ServerType][]ServerOS{
+		D1M1Medium: {UbuntuBionic},
+		D1M2Medium: {UbuntuBionic},
+		D1M3Medium: {UbuntuBionic},
+		D1M4Medium: {UbuntuBionic},
+	}
+)
+
+// +kubebuilder:object:generate=false
+type staticCatalog struct{}
+
+func (staticCatalog) Offered(t ServerType, l LocationID) (bool, bool) {
+	for _, known := range knownServerTypes {
+		if known != t {
+			continue
+		}
+		if locations, ok := serverTypeLocations[t]; ok {
+			return containsLocation(locations, l), true
+		}
+		return containsLocation(knownLocations, l), true
+	}
+	return false, false
+}
+
+func (staticCatalog) KnownLocation(l LocationID) bool {
+	return containsLocation(knownLocations, l)
+}
+
+// SupportedOS returns the OS images that can be installed on the server type.
+func (t ServerType) SupportedOS() []ServerOS {
+	if oss, ok := serverTypeOSs[t]; ok {
+		return oss
+	}
+	return knownOSs
+}
+
+// ServerCapacity describes the compute resources of a server type.
+// +kubebuilder:object:generate=false
+type ServerCapacity struct {
+	Cores    int32
+	MemoryGB int32
+}
+
+// serverTypeCapacities lists the cores and memory of the known server types.
+var serverTypeCapacities = map[ServerType]ServerCapacity{
+	S1C1Small:  {Cores: 4, MemoryGB: 32},
+	S1C1Medium: {Cores: 8, MemoryGB: 64},
+	S1C2Medium: {Cores: 16, MemoryGB: 128},
+	S1C2Large:  {Cores: 20, MemoryGB: 256},
+	D1C1Small:  {Cores: 16, MemoryGB: 64},
+	D1C2Small:  {Cores: 20, MemoryGB: 64},
+	D1C3Small:  {Cores: 24, MemoryGB: 64},
+	D1C4Small:  {Cores: 40, MemoryGB: 64},
+	D1C1Medium: {Cores: 16, MemoryGB: 128},
+	D1C2Medium: {Cores: 20, MemoryGB: 128},
+	D1C3Medium: {Cores: 24, MemoryGB: 128},
+	D1C4Medium: {Cores: 40, MemoryGB: 128},
+	D1C1Large:  {Cores: 16, MemoryGB: 256},
+	D1C2Large:  {Cores: 20, MemoryGB: 256},
+	D1C3Large:  {Cores: 24, MemoryGB: 256},
+	D1C4Large:  {Cores: 40, MemoryGB: 256},
+	D1M1Medium: {Cores: 16, MemoryGB: 512},
+	D1M2Medium: {Cores: 20, MemoryGB: 512},
+	D1M3Medium: {Cores: 24, MemoryGB: 512},
+	D1M4Medium: {Cores: 40, MemoryGB: 512},
+}
+
+// Capacity returns the cores and memory of the server type and whether the
+// type is known.
+func (t ServerType) Capacity() (ServerCapacity, bool) {
+	c, ok := serverTypeCapacities[t]
+	return c, ok
+}
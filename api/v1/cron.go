@@ -0,0 +1,192 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cronSchedule is a parsed standard cron expression with the fields minute,
+// hour, day of month, month and day of week. Each field accepts *, numbers,
+// ranges, lists and steps.
+// +kubebuilder:object:generate=false
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record unrestricted day fields, those starting with
+	// *, including stepped fields such as */2. When both day fields are
+	// restricted a day matching either of them matches, as in Vixie cron.
+	domAny, dowAny bool
+}
+
+// +kubebuilder:object:generate=false
+type cronField struct {
+	min, max int
+}
+
+var cronFields = []cronField{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// parseCron parses a five field cron expression.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields in cron expression %q", len(cronFields), expr)
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		bits[i] = b
+	}
+	// Sunday may be written as 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(fields[2], `*`),
+		dowAny: strings.HasPrefix(fields[4], `*`),
+	}, nil
+}
+
+func parseCronField(field string, r cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, `,`) {
+		step := 1
+		if i := strings.Index(part, `/`); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+			part = part[:i]
+		}
+		lo, hi := r.min, r.max
+		if r.max == 6 {
+			// day of week also accepts 7 for Sunday
+			hi = 7
+		}
+		if part != `*` {
+			bounds := strings.SplitN(part, `-`, 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				hi = r.max
+			}
+			max := r.max
+			if r.max == 6 {
+				max = 7
+			}
+			if lo < r.min || hi > max || lo > hi {
+				return 0, fmt.Errorf("value %q out of range %d-%d", part, r.min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t matching the schedule, in the location
+// of t. It returns the zero time when nothing matches within five years.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Power actions of a PowerTransition.
+const (
+	PowerOn  = `on`
+	PowerOff = `off`
+)
+
+// NextTransition returns the next power transition of the schedule after now.
+// The server should be powered on when the next transition powers it off.
+func (s *PowerSchedule) NextTransition(now time.Time) (PowerTransition, error) {
+	loc := time.UTC
+	if len(s.TimeZone) > 0 {
+		var err error
+		if loc, err = time.LoadLocation(s.TimeZone); err != nil {
+			return PowerTransition{}, err
+		}
+	}
+	on, err := parseCron(s.On)
+	if err != nil {
+		return PowerTransition{}, err
+	}
+	off, err := parseCron(s.Off)
+	if err != nil {
+		return PowerTransition{}, err
+	}
+	nextOn := on.Next(now.In(loc))
+	nextOff := off.Next(now.In(loc))
+	switch {
+	case nextOn.IsZero() && nextOff.IsZero():
+		return PowerTransition{}, fmt.Errorf(`power schedule never fires`)
+	case nextOn.IsZero() || (!nextOff.IsZero() && nextOff.Before(nextOn)):
+		return PowerTransition{Action: PowerOff, Time: metav1.Time{Time: nextOff}}, nil
+	default:
+		return PowerTransition{Action: PowerOn, Time: metav1.Time{Time: nextOn}}, nil
+	}
+}
@@ -0,0 +1,163 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{`0 8 * * 1-5`, false},
+		{`*/15 * * * *`, false},
+		{`0 0 1,15 * *`, false},
+		{`0 0 * * 7`, false},
+		{`0-30/10 9-17 * 1-6 *`, false},
+		{`* * * *`, true},
+		{`* * * * * *`, true},
+		{`60 * * * *`, true},
+		{`* 24 * * *`, true},
+		{`* * 0 * *`, true},
+		{`* * * 13 *`, true},
+		{`* * * * 8`, true},
+		{`*/0 * * * *`, true},
+		{`5-1 * * * *`, true},
+		{`a * * * *`, true},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	now := time.Date(2020, time.June, 3, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{`weekday mornings`, `0 8 * * 1-5`, time.Date(2020, time.June, 4, 8, 0, 0, 0, time.UTC)},
+		{`step`, `*/15 * * * *`, time.Date(2020, time.June, 3, 10, 45, 0, 0, time.UTC)},
+		{`strictly after now`, `30 10 * * *`, time.Date(2020, time.June, 4, 10, 30, 0, 0, time.UTC)},
+		{`day of month list`, `0 0 1,15 * *`, time.Date(2020, time.June, 15, 0, 0, 0, 0, time.UTC)},
+		{`sunday as 0`, `0 0 * * 0`, time.Date(2020, time.June, 7, 0, 0, 0, 0, time.UTC)},
+		{`sunday as 7`, `0 0 * * 7`, time.Date(2020, time.June, 7, 0, 0, 0, 0, time.UTC)},
+		{`leap day`, `0 0 29 2 *`, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{`never`, `0 0 30 2 *`, time.Time{}},
+		{`both days restricted matches either`, `0 0 13 * 5`, time.Date(2020, time.June, 5, 0, 0, 0, 0, time.UTC)},
+		{`stepped day of month is unrestricted`, `0 0 */2 * 1`, time.Date(2020, time.June, 15, 0, 0, 0, 0, time.UTC)},
+		{`stepped day of week is unrestricted`, `0 0 1 * */2`, time.Date(2020, time.August, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tt.expr, err)
+			}
+			if got := c.Next(now); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPowerScheduleNextTransition(t *testing.T) {
+	weekdays := PowerSchedule{On: `0 8 * * 1-5`, Off: `0 18 * * 1-5`}
+	phoenix := weekdays
+	phoenix.TimeZone = `America/Phoenix`
+	invalid := weekdays
+	invalid.TimeZone = `Nowhere/Special`
+
+	tests := []struct {
+		name     string
+		schedule PowerSchedule
+		now      time.Time
+		want     PowerTransition
+		wantErr  bool
+	}{
+		{
+			name:     `during the day`,
+			schedule: weekdays,
+			now:      time.Date(2020, time.June, 3, 10, 30, 0, 0, time.UTC),
+			want:     PowerTransition{Action: PowerOff, Time: metav1Time(2020, time.June, 3, 18, 0)},
+		},
+		{
+			name:     `at night`,
+			schedule: weekdays,
+			now:      time.Date(2020, time.June, 3, 19, 0, 0, 0, time.UTC),
+			want:     PowerTransition{Action: PowerOn, Time: metav1Time(2020, time.June, 4, 8, 0)},
+		},
+		{
+			name:     `over the weekend`,
+			schedule: weekdays,
+			now:      time.Date(2020, time.June, 6, 12, 0, 0, 0, time.UTC),
+			want:     PowerTransition{Action: PowerOn, Time: metav1Time(2020, time.June, 8, 8, 0)},
+		},
+		{
+			name:     `time zone`,
+			schedule: phoenix,
+			now:      time.Date(2020, time.June, 3, 10, 30, 0, 0, time.UTC),
+			want:     PowerTransition{Action: PowerOn, Time: metav1Time(2020, time.June, 3, 15, 0)},
+		},
+		{
+			name:     `invalid time zone`,
+			schedule: invalid,
+			now:      time.Date(2020, time.June, 3, 10, 30, 0, 0, time.UTC),
+			wantErr:  true,
+		},
+		{
+			name:     `invalid expression`,
+			schedule: PowerSchedule{On: `0 8 * *`, Off: `0 18 * * *`},
+			now:      time.Date(2020, time.June, 3, 10, 30, 0, 0, time.UTC),
+			wantErr:  true,
+		},
+		{
+			name:     `never fires`,
+			schedule: PowerSchedule{On: `0 0 30 2 *`, Off: `0 0 31 4 *`},
+			now:      time.Date(2020, time.June, 3, 10, 30, 0, 0, time.UTC),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.NextTransition(tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NextTransition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Action != tt.want.Action || !got.Time.Equal(&tt.want.Time) {
+				t.Errorf("NextTransition() = %s at %v, want %s at %v", got.Action, got.Time, tt.want.Action, tt.want.Time)
+			}
+		})
+	}
+}
+
+func metav1Time(year int, month time.Month, day, hour, min int) metav1.Time {
+	return metav1.Time{Time: time.Date(year, month, day, hour, min, 0, 0, time.UTC)}
+}
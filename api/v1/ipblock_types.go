@@ -0,0 +1,91 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IPBlockSpec defines the desired state of IPBlock
+type IPBlockSpec struct {
+	// Location ID where the IP block is allocated.
+	// +kubebuilder:validation:Required
+	Location LocationID `json:"location,omitempty"`
+
+	// CIDR block size of the allocation.
+	// +kubebuilder:validation:Required
+	CIDRBlockSize CIDRBlockSize `json:"cidrBlockSize,omitempty"`
+
+	// Description of the IP block.
+	// +kubebuilder:validation:MaxLength=250
+	// +kubebuilder:validation:Optional
+	Description string `json:"description,omitempty"`
+}
+
+// CIDRBlockSize describes the size of a public IP block allocation.
+// Only one of the following sizes may be specified.
+// +kubebuilder:validation:Enum=/31;/30;/29;/28;/27;/26;/25;/24;/23;/22
+type CIDRBlockSize string
+
+const (
+	CIDRBlockSize31 CIDRBlockSize = `/31`
+	CIDRBlockSize30 CIDRBlockSize = `/30`
+	CIDRBlockSize29 CIDRBlockSize = `/29`
+	CIDRBlockSize28 CIDRBlockSize = `/28`
+	CIDRBlockSize27 CIDRBlockSize = `/27`
+	CIDRBlockSize26 CIDRBlockSize = `/26`
+	CIDRBlockSize25 CIDRBlockSize = `/25`
+	CIDRBlockSize24 CIDRBlockSize = `/24`
+	CIDRBlockSize23 CIDRBlockSize = `/23`
+	CIDRBlockSize22 CIDRBlockSize = `/22`
+)
+
+// IPBlockStatus defines the observed state of IPBlock
+type IPBlockStatus struct {
+	BMCIPBlockID         string `json:"id,omitempty"`
+	BMCStatus            string `json:"status,omitempty"`
+	CIDR                 string `json:"cidr,omitempty"`
+	AssignedResourceID   string `json:"assignedResourceId,omitempty"`
+	AssignedResourceType string `json:"assignedResourceType,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPBlock is the Schema for the ipblocks API
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.spec.location`
+// +kubebuilder:printcolumn:name="CIDR",type=string,JSONPath=`.status.cidr`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+type IPBlock struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPBlockSpec   `json:"spec,omitempty"`
+	Status IPBlockStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPBlockList contains a list of IPBlock
+type IPBlockList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPBlock `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IPBlock{}, &IPBlockList{})
+}
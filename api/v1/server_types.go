@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	resource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -29,10 +30,16 @@ type ServerSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Hostname of server.
+	// Name of the ServerClass whose values are used for any field not set on this server.
+	// Class values are merged at admission time.
+	// +kubebuilder:validation:Optional
+	ServerClassName string `json:"serverClassName,omitempty"`
+
+	// Hostname of server. Dot separated labels of letters, digits and hyphens that
+	// start and end with a letter or digit. Must contain at least one letter.
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=100
-	// +kubebuilder:vaildation:Pattern=^(?=.*[a-zA-Z])([a-zA-Z0-9().-])+$
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`
 	// +kubebuilder:validation:Required
 	Hostname string `json:"hostname,omitempty"`
 
@@ -52,6 +59,20 @@ type ServerSpec struct {
 	// +kubebuilder:validation:Required
 	Location LocationID `json:"location,omitempty"`
 
+	// Locations tried in order when BMC has no inventory for the server in location.
+	// Cannot be combined with IP blocks, which are bound to a location.
+	// +kubebuilder:validation:Optional
+	LocationPreferences []LocationID `json:"locationPreferences,omitempty"`
+
+	// Server types tried in order when BMC has no inventory for the server type.
+	// All types are tried in a location before moving to the next preferred location.
+	// +kubebuilder:validation:Optional
+	TypePreferences []ServerType `json:"typePreferences,omitempty"`
+
+	// Pricing model of the server. Defaults to HOURLY.
+	// +kubebuilder:validation:Optional
+	PricingModel ServerPricingModel `json:"pricingModel,omitempty"`
+
 	// Whether or not to install SSH Keys marked as default in additionl to any SSH keys speficied on this resource.
 	// Defaults to true.
 	InstallDefaultSSHKeys *bool `json:"installDefaultSshKeys"`
@@ -63,8 +84,214 @@ type ServerSpec struct {
 	// The type of networks where this server should be attached.
 	// +kubebuilder:validation:Optional
 	NetworkType NetworkType `json:"networkType,omitempty"`
+
+	// Network configuration applied when the server is provisioned.
+	// +kubebuilder:validation:Optional
+	Network *ServerNetwork `json:"network,omitempty"`
+
+	// Reference to a key in a Secret in the same namespace holding cloud-init user data passed to the server at provisioning.
+	// +kubebuilder:validation:Optional
+	UserDataSecretRef *SecretKeyReference `json:"userDataSecretRef,omitempty"`
+
+	// Secret in the same namespace to which the controller writes the server ID, hostname,
+	// IP addresses and any one-time credentials returned when the server is created.
+	// The Secret is owned by this server; an existing Secret owned by anything else is not
+	// overwritten.
+	// +kubebuilder:validation:Optional
+	WriteConnectionSecretToRef *corev1.LocalObjectReference `json:"writeConnectionSecretToRef,omitempty"`
+
+	// Headless Service and Endpoints managed for this server so workloads in the cluster
+	// can reach it by a stable DNS name.
+	// +kubebuilder:validation:Optional
+	Service *ServerService `json:"service,omitempty"`
+
+	// Public DNS records published for this server through external-dns.
+	// +kubebuilder:validation:Optional
+	DNS *ServerDNS `json:"dns,omitempty"`
+
+	// How changes to fields that are fixed once the BMC server exists are applied.
+	// Changes to those fields are rejected when unset.
+	// +kubebuilder:validation:Optional
+	UpdatePolicy UpdatePolicy `json:"updatePolicy,omitempty"`
+
+	// Lifetime of the server counted from its creation. The server is deleted once it expires.
+	// +kubebuilder:validation:Optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// Time at which the server is deleted. The earlier of ttl and expiresAt applies when both are set.
+	// +kubebuilder:validation:Optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Times at which the server is powered on and off.
+	// +kubebuilder:validation:Optional
+	PowerSchedule *PowerSchedule `json:"powerSchedule,omitempty"`
+
+	// Whether to provision a new BMC server when the current one is deleted outside
+	// of the controller. When false the server is marked gone.
+	// +kubebuilder:validation:Optional
+	RecreateOnLoss bool `json:"recreateOnLoss,omitempty"`
+
+	// What to do when the BMC server no longer matches this spec, for example after
+	// changes made in the BMC portal. Defaults to Report.
+	// +kubebuilder:validation:Optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// What to do with the Kubernetes Node running on this server before the BMC server is deleted.
+	// Defaults to None.
+	// +kubebuilder:validation:Optional
+	NodeDeletionPolicy NodeDeletionPolicy `json:"nodeDeletionPolicy,omitempty"`
+
+	// Tags assigned to the BMC server. Tags are applied on creation and kept in sync afterwards.
+	// +kubebuilder:validation:Optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Keys of labels on this resource that are mirrored to the BMC server as tags.
+	// Values in tags take precedence over mirrored labels with the same key.
+	// +kubebuilder:validation:Optional
+	TagLabels []string `json:"tagLabels,omitempty"`
+}
+
+// ServerNetwork describes additional network resources attached to a server at provisioning.
+type ServerNetwork struct {
+	// Public IP blocks assigned to the server.
+	// +kubebuilder:validation:Optional
+	IPBlocks []ServerIPBlock `json:"ipBlocks,omitempty"`
+}
+
+// ServerIPBlock references a public IP block by IPBlock resource name or by BMC resource ID.
+// Exactly one of name or id should be set.
+type ServerIPBlock struct {
+	// Name of an IPBlock resource in the same namespace as the server.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// BMC resource ID of an IP block not managed by this controller.
+	// +kubebuilder:validation:Optional
+	ID string `json:"id,omitempty"`
+}
+
+// SecretKeyReference selects a key of a Secret in the namespace of the referring resource.
+type SecretKeyReference struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+
+	// Key within the Secret. Defaults to value.
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+}
+
+// ServerDNS describes the DNS records published for a server. The record name is
+// the server hostname within the zone and its targets are the public IP addresses.
+type ServerDNS struct {
+	// DNS zone the record is created in, for example example.com.
+	// +kubebuilder:validation:Required
+	Zone string `json:"zone"`
+
+	// TTL of the record in seconds. The external-dns provider default is used when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+// ServerService describes the headless Service managed for a server.
+type ServerService struct {
+	// Name of the Service. Defaults to the name of the server.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Which server addresses the endpoints point to. Defaults to Private.
+	// +kubebuilder:validation:Optional
+	AddressType ServiceAddressType `json:"addressType,omitempty"`
+
+	// Ports exposed by the Service.
+	// +kubebuilder:validation:Optional
+	Ports []ServerServicePort `json:"ports,omitempty"`
+}
+
+// ServiceAddressType selects the server addresses used as Service endpoints.
+// Only one of the following address types may be specified.
+// If none of the following address types are specified, the default one is Private.
+// +kubebuilder:validation:Enum=Private;Public
+type ServiceAddressType string
+
+const (
+	ServiceAddressPrivate ServiceAddressType = `Private`
+	ServiceAddressPublic  ServiceAddressType = `Public`
+)
+
+// ServerServicePort is a port exposed by the Service of a server.
+type ServerServicePort struct {
+	// Name of the port. Required when more than one port is exposed.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Port number on the server.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Protocol of the port. Defaults to TCP.
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +kubebuilder:validation:Optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+}
+
+// PowerSchedule describes recurring power on and off times of a server. The server is
+// kept powered on between an on time and the following off time and powered off otherwise.
+type PowerSchedule struct {
+	// Cron expression (minute hour day-of-month month day-of-week) of the power on times.
+	// +kubebuilder:validation:Required
+	On string `json:"on"`
+
+	// Cron expression (minute hour day-of-month month day-of-week) of the power off times.
+	// +kubebuilder:validation:Required
+	Off string `json:"off"`
+
+	// IANA time zone the cron expressions are evaluated in. Defaults to UTC.
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
+// UpdatePolicy describes how changes to provisioning fields are applied to an existing BMC server.
+// Only one of the following policies may be specified.
+// +kubebuilder:validation:Enum=Reprovision;Recreate
+type UpdatePolicy string
+
+const (
+	// UpdateReprovision allows changing hostname, description, OS, SSH keys and user data.
+	// Changes are applied by reprovisioning the server in place, which erases its disks.
+	UpdateReprovision UpdatePolicy = `Reprovision`
+	// UpdateRecreate additionally allows changing type, location and network type.
+	// Changes to those fields replace the BMC server with a new one.
+	UpdateRecreate UpdatePolicy = `Recreate`
+)
+
+// DriftPolicy describes how the controller reacts to a BMC server that no longer matches its spec.
+// Only one of the following policies may be specified.
+// If none of the following policies are specified, the default one is Report.
+// +kubebuilder:validation:Enum=Report;Reprovision
+type DriftPolicy string
+
+const (
+	// DriftReport sets the Drifted condition and emits an event.
+	DriftReport DriftPolicy = `Report`
+	// DriftReprovision additionally reprovisions the server from its spec.
+	// Reprovisioning erases the server disks.
+	DriftReprovision DriftPolicy = `Reprovision`
+)
+
+// NodeDeletionPolicy describes how the Node linked to a server is treated before the server is deleted.
+// Only one of the following policies may be specified.
+// If none of the following policies are specified, the default one is None.
+// +kubebuilder:validation:Enum=None;Cordon;Drain
+type NodeDeletionPolicy string
+
+const (
+	NodeDeletionNone   NodeDeletionPolicy = `None`
+	NodeDeletionCordon NodeDeletionPolicy = `Cordon`
+	NodeDeletionDrain  NodeDeletionPolicy = `Drain`
+)
+
 // NetworkType represents the type of networking configuraiton a server should use.
 // Only one of the following network types may be specified.
 // If none of the following network types are specified, the default one is PublicAndPrivate.
@@ -77,9 +304,8 @@ const (
 )
 
 // LocationID identifies a BMC region.
-// Only one of the following locations may be specified.
-// If none of the following locations are specified, the default one is Phoenix.
-// +kubebuilder:validation:Enum=PHX;ASH;SGP;NLD
+// Locations are checked against the BMC product catalog when a server is created.
+// If no location is specified, the default one is Phoenix.
 type LocationID string
 
 const (
@@ -101,9 +327,8 @@ const (
 )
 
 // ServerType describes the hardware to allocate for this server.
-// Only one of the following server types may be specified.
-// If none of the following types are specified, the default one is S1C1Small.
-// +kubebuilder:validation:Enum=s1.c1.small;s1.c1.medium;s1.c2.medium;s1.c2.large;d1.c1.small;d1.c2.small;d1.c3.small;d1.c4.small;d1.c1.medium;d1.c2.medium;d1.c3.medium;d1.c4.medium;d1.c1.large;d1.c2.large;d1.c3.large;d1.c4.large;d1.m1.medium;d1.m2.medium;d1.m3.medium;d1.m4.medium
+// Server types are checked against the BMC product catalog when a server is created.
+// If no type is specified, the default one is S1C1Small.
 type ServerType string
 
 const (
@@ -148,22 +373,114 @@ type ServerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	BMCServerID        string            `json:"id,omitempty"`
-	BMCStatus          string            `json:"status,omitempty"`
-	CPU                string            `json:"cpu,omitempty"`
-	CPUCount           int32             `json:"cpuCount,omitempty"`
-	CPUCores           int32             `json:"coresPerCpu,omitempty"`
-	CPUFrequency       resource.Quantity `json:"cpuFrequency,omitempty"`
-	Ram                string            `json:"ram,omitempty"`
-	Storage            string            `json:"storage,omitempty"`
-	PrivateIPAddresses []string          `json:"privateIpAddresses,omitempty"`
-	PublicIPAddresses  []string          `json:"publicIpAddresses,omitempty"`
+	BMCServerID        string             `json:"id,omitempty"`
+	BMCStatus          string             `json:"status,omitempty"`
+	CPU                string             `json:"cpu,omitempty"`
+	CPUCount           int32              `json:"cpuCount,omitempty"`
+	CPUCores           int32              `json:"coresPerCpu,omitempty"`
+	CPUFrequency       resource.Quantity  `json:"cpuFrequency,omitempty"`
+	Ram                string             `json:"ram,omitempty"`
+	Storage            string             `json:"storage,omitempty"`
+	PrivateIPAddresses []string           `json:"privateIpAddresses,omitempty"`
+	PublicIPAddresses  []string           `json:"publicIpAddresses,omitempty"`
+	Tags               []ServerTag        `json:"tags,omitempty"`
+	PricingModel       ServerPricingModel `json:"pricingModel,omitempty"`
+	ProvisionedOn      *metav1.Time       `json:"provisionedOn,omitempty"`
+
+	// Estimated hourly rate and cost accrued since the server was provisioned, in USD.
+	HourlyRate    string `json:"hourlyRate,omitempty"`
+	EstimatedCost string `json:"estimatedCost,omitempty"`
+
+	// Server type and location the server was provisioned with. They differ from the
+	// spec when one of the type or location preferences was used.
+	Type     ServerType `json:"type,omitempty"`
+	Location LocationID `json:"location,omitempty"`
+
+	// Index of the type and location preference tried by the next create attempt.
+	PlacementAttempt int `json:"placementAttempt,omitempty"`
+
+	// Node running on this server, once the server has joined the cluster.
+	NodeRef *corev1.ObjectReference `json:"nodeRef,omitempty"`
+
+	// Current service state of the server.
+	Conditions []ServerCondition `json:"conditions,omitempty"`
+
+	// Time at which the server expires.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Lead time of the last expiry warning sent for the server.
+	ExpiryWarning *metav1.Duration `json:"expiryWarning,omitempty"`
+
+	// Next power transition required by the power schedule.
+	NextPowerTransition *PowerTransition `json:"nextPowerTransition,omitempty"`
+}
+
+// ServerConditionType is a valid value for ServerCondition.Type
+type ServerConditionType string
+
+const (
+	// ServerDrifted is true when the BMC server no longer matches the spec.
+	ServerDrifted ServerConditionType = `Drifted`
+	// ServerGone is true when the BMC server was deleted outside of the controller.
+	ServerGone ServerConditionType = `Gone`
+)
+
+// ServerCondition describes the state of a server at a certain point.
+type ServerCondition struct {
+	Type               ServerConditionType    `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition of the given type or nil if it is not set.
+func (s *ServerStatus) GetCondition(t ServerConditionType) *ServerCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == t {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or replaces the condition of the same type. The transition
+// time is kept when the condition status does not change.
+func (s *ServerStatus) SetCondition(c ServerCondition) {
+	if existing := s.GetCondition(c.Type); existing != nil {
+		if existing.Status == c.Status {
+			c.LastTransitionTime = existing.LastTransitionTime
+		}
+		*existing = c
+		return
+	}
+	s.Conditions = append(s.Conditions, c)
+}
+
+// PowerTransition is a scheduled change of the power state of a server.
+type PowerTransition struct {
+	// Power action, either on or off.
+	Action string      `json:"action"`
+	Time   metav1.Time `json:"time"`
+}
+
+// ServerTag is a tag assignment as reported by BMC.
+type ServerTag struct {
+	ID           string `json:"id,omitempty"`
+	Name         string `json:"name"`
+	Value        string `json:"value,omitempty"`
+	IsBillingTag bool   `json:"isBillingTag,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 
 // Server is the Schema for the servers API
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.status.type`,priority=1
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`,priority=1
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.status.nodeRef.name`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+// +kubebuilder:printcolumn:name="Cost",type=string,JSONPath=`.status.estimatedCost`,priority=1
 type Server struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,313 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// Limits enforced by the BMC servers API.
+	maxHostnameLength    = 100
+	maxHostnameLabel     = 63
+	maxDescriptionLength = 250
+)
+
+var (
+	hostnameLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+	letterPattern        = regexp.MustCompile(`[a-zA-Z]`)
+
+	// BMC resource IDs are 24 hexadecimal characters.
+	bmcIDPattern = regexp.MustCompile(`^[0-9a-f]{24}$`)
+)
+
+// validateServerSpec returns the errors in a server spec that the BMC API
+// would otherwise reject after the server has been admitted.
+func validateServerSpec(spec *ServerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateHostname(spec.Hostname, path.Child(`hostname`))...)
+	allErrs = append(allErrs, validateDescription(spec.Description, path.Child(`description`))...)
+	allErrs = append(allErrs, validateSSHKeyIDs(spec.SSHKeyIDs, path.Child(`sshKeyIds`))...)
+	allErrs = append(allErrs, validateNetwork(spec, path)...)
+	allErrs = append(allErrs, validateProduct(spec, path)...)
+	allErrs = append(allErrs, validatePowerSchedule(spec.PowerSchedule, path.Child(`powerSchedule`))...)
+	allErrs = append(allErrs, validateTTL(spec.TTL, path.Child(`ttl`))...)
+	return allErrs
+}
+
+// validateServerSpecUpdate validates the fields of a server spec that differ
+// from the previous spec. Unchanged fields are not validated again so that a
+// change in the catalog does not block updates such as finalizer removal.
+func validateServerSpecUpdate(spec, prev *ServerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Hostname != prev.Hostname {
+		allErrs = append(allErrs, validateHostname(spec.Hostname, path.Child(`hostname`))...)
+	}
+	if spec.Description != prev.Description {
+		allErrs = append(allErrs, validateDescription(spec.Description, path.Child(`description`))...)
+	}
+	if !reflect.DeepEqual(spec.SSHKeyIDs, prev.SSHKeyIDs) {
+		allErrs = append(allErrs, validateSSHKeyIDs(spec.SSHKeyIDs, path.Child(`sshKeyIds`))...)
+	}
+	if !reflect.DeepEqual(spec.Network, prev.Network) || !reflect.DeepEqual(spec.LocationPreferences, prev.LocationPreferences) {
+		allErrs = append(allErrs, validateNetwork(spec, path)...)
+	}
+	if spec.Type != prev.Type || spec.Location != prev.Location || spec.OS != prev.OS ||
+		!reflect.DeepEqual(spec.TypePreferences, prev.TypePreferences) ||
+		!reflect.DeepEqual(spec.LocationPreferences, prev.LocationPreferences) {
+		allErrs = append(allErrs, validateProduct(spec, path)...)
+	}
+	if !reflect.DeepEqual(spec.PowerSchedule, prev.PowerSchedule) {
+		allErrs = append(allErrs, validatePowerSchedule(spec.PowerSchedule, path.Child(`powerSchedule`))...)
+	}
+	if !reflect.DeepEqual(spec.TTL, prev.TTL) {
+		allErrs = append(allErrs, validateTTL(spec.TTL, path.Child(`ttl`))...)
+	}
+	return allErrs
+}
+
+// validateService checks that the Service name, which defaults to the server
+// name, is a valid DNS-1035 label.
+func validateService(service *ServerService, serverName string, path *field.Path) field.ErrorList {
+	if service == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if len(service.Name) > 0 {
+		for _, msg := range validation.IsDNS1035Label(service.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child(`name`), service.Name, msg))
+		}
+	} else if len(serverName) > 0 {
+		for _, msg := range validation.IsDNS1035Label(serverName) {
+			allErrs = append(allErrs, field.Invalid(path.Child(`name`), serverName, `defaults to the server name: `+msg))
+		}
+	}
+	return allErrs
+}
+
+func validateDescription(description string, path *field.Path) field.ErrorList {
+	if len(description) > maxDescriptionLength {
+		return field.ErrorList{field.TooLong(path, description, maxDescriptionLength)}
+	}
+	return nil
+}
+
+func validateSSHKeyIDs(ids []string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]bool{}
+	for i, id := range ids {
+		if !bmcIDPattern.MatchString(id) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), id, `must be a BMC resource ID of 24 lowercase hexadecimal characters`))
+		} else if seen[id] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), id))
+		}
+		seen[id] = true
+	}
+	return allErrs
+}
+
+func validateNetwork(spec *ServerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Network == nil {
+		return allErrs
+	}
+	for i, block := range spec.Network.IPBlocks {
+		p := path.Child(`network`, `ipBlocks`).Index(i)
+		if (len(block.Name) > 0) == (len(block.ID) > 0) {
+			allErrs = append(allErrs, field.Invalid(p, block, `exactly one of name or id must be set`))
+		} else if len(block.ID) > 0 && !bmcIDPattern.MatchString(block.ID) {
+			allErrs = append(allErrs, field.Invalid(p.Child(`id`), block.ID, `must be a BMC resource ID of 24 lowercase hexadecimal characters`))
+		}
+	}
+	if len(spec.LocationPreferences) > 0 && len(spec.Network.IPBlocks) > 0 {
+		allErrs = append(allErrs, field.Forbidden(path.Child(`locationPreferences`), `cannot be combined with network.ipBlocks`))
+	}
+	return allErrs
+}
+
+// validateProduct checks the server type, location, OS and the preferences
+// against the catalog. Every preferred type and location must be offered in
+// at least one of the candidate placements, and every candidate type must
+// support the OS.
+func validateProduct(spec *ServerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if offered, known := Catalog.Offered(spec.Type, spec.Location); !known {
+		allErrs = append(allErrs, field.Invalid(path.Child(`type`), spec.Type, `not offered by BMC`))
+	} else if !offered {
+		allErrs = append(allErrs, field.Invalid(path.Child(`type`), spec.Type, `not offered in location `+string(spec.Location)))
+	}
+	if oss := spec.Type.SupportedOS(); len(spec.OS) > 0 && !containsOS(oss, spec.OS) {
+		supported := make([]string, len(oss))
+		for i, o := range oss {
+			supported[i] = string(o)
+		}
+		allErrs = append(allErrs, field.NotSupported(path.Child(`os`), spec.OS, supported))
+	}
+
+	types := append([]ServerType{spec.Type}, spec.TypePreferences...)
+	locations := append([]LocationID{spec.Location}, spec.LocationPreferences...)
+	for i, t := range spec.TypePreferences {
+		p := path.Child(`typePreferences`).Index(i)
+		if containsServerType(types[:i+1], t) {
+			allErrs = append(allErrs, field.Duplicate(p, t))
+			continue
+		}
+		if _, known := Catalog.Offered(t, spec.Location); !known {
+			allErrs = append(allErrs, field.Invalid(p, t, `not offered by BMC`))
+		} else if !offeredInAny(t, locations) {
+			allErrs = append(allErrs, field.Invalid(p, t, `not offered in any preferred location`))
+		} else if len(spec.OS) > 0 && !containsOS(t.SupportedOS(), spec.OS) {
+			allErrs = append(allErrs, field.Invalid(p, t, `does not support OS `+string(spec.OS)))
+		}
+	}
+	for i, l := range spec.LocationPreferences {
+		p := path.Child(`locationPreferences`).Index(i)
+		if containsLocation(locations[:i+1], l) {
+			allErrs = append(allErrs, field.Duplicate(p, l))
+			continue
+		}
+		offered := false
+		for _, t := range types {
+			if o, _ := Catalog.Offered(t, l); o {
+				offered = true
+				break
+			}
+		}
+		if !offered {
+			allErrs = append(allErrs, field.Invalid(p, l, `no preferred server type is offered in this location`))
+		}
+	}
+	return allErrs
+}
+
+// validateTypeLocation checks an optional server type and location against
+// the catalog.
+func validateTypeLocation(t ServerType, l LocationID, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(l) > 0 && !Catalog.KnownLocation(l) {
+		allErrs = append(allErrs, field.Invalid(path.Child(`location`), l, `not a BMC location`))
+	}
+	if len(t) > 0 {
+		if offered, known := Catalog.Offered(t, l); !known {
+			allErrs = append(allErrs, field.Invalid(path.Child(`type`), t, `not offered by BMC`))
+		} else if len(l) > 0 && !offered {
+			allErrs = append(allErrs, field.Invalid(path.Child(`type`), t, `not offered in location `+string(l)))
+		}
+	}
+	return allErrs
+}
+
+// offeredInAny reports whether the server type is offered in any of the locations.
+func offeredInAny(t ServerType, locations []LocationID) bool {
+	for _, l := range locations {
+		if offered, _ := Catalog.Offered(t, l); offered {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePowerSchedule(schedule *PowerSchedule, path *field.Path) field.ErrorList {
+	if schedule == nil {
+		return nil
+	}
+	if _, err := schedule.NextTransition(time.Now()); err != nil {
+		return field.ErrorList{field.Invalid(path, schedule, err.Error())}
+	}
+	return nil
+}
+
+func validateTTL(ttl *metav1.Duration, path *field.Path) field.ErrorList {
+	if ttl != nil && ttl.Duration <= 0 {
+		return field.ErrorList{field.Invalid(path, ttl.Duration.String(), `must be positive`)}
+	}
+	return nil
+}
+
+// validateHostname checks a hostname against RFC 1123 and the BMC requirement
+// that it contains at least one letter.
+func validateHostname(hostname string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(hostname) == 0 {
+		return append(allErrs, field.Required(path, ``))
+	}
+	if len(hostname) > maxHostnameLength {
+		allErrs = append(allErrs, field.TooLong(path, hostname, maxHostnameLength))
+	}
+	for _, label := range strings.Split(hostname, `.`) {
+		if len(label) == 0 || len(label) > maxHostnameLabel || !hostnameLabelPattern.MatchString(label) {
+			allErrs = append(allErrs, field.Invalid(path, hostname, `each dot separated label must be 1 to 63 letters, digits or hyphens and start and end with a letter or digit`))
+			break
+		}
+	}
+	if !letterPattern.MatchString(hostname) {
+		allErrs = append(allErrs, field.Invalid(path, hostname, `must contain at least one letter`))
+	}
+	return allErrs
+}
+
+func containsLocation(list []LocationID, l LocationID) bool {
+	for _, v := range list {
+		if v == l {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOS(list []ServerOS, os ServerOS) bool {
+	for _, v := range list {
+		if v == os {
+			return true
+		}
+	}
+	return false
+}
+
+func containsServerType(list []ServerType, t ServerType) bool {
+	for _, v := range list {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsNetworkType(list []NetworkType, n NetworkType) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validSpec() ServerSpec {
+	return ServerSpec{
+		Hostname: `web-1`,
+		OS:       UbuntuBionic,
+		Type:     S1C1Small,
+		Location: Phoenix,
+	}
+}
+
+// errorFields returns the field paths of the errors in a list.
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestValidateServerSpec(t *testing.T) {
+	const keyA, keyB = `5fa54d1e91867c03a0a7b4a4`, `5fa54d1e91867c03a0a7b4a5`
+	tests := []struct {
+		name   string
+		mutate func(*ServerSpec)
+		want   []string
+	}{
+		{`valid`, func(s *ServerSpec) {}, nil},
+		{`dotted hostname`, func(s *ServerSpec) { s.Hostname = `web-1.example.com` }, nil},
+		{`missing hostname`, func(s *ServerSpec) { s.Hostname = `` }, []string{`spec.hostname`}},
+		{`invalid hostname`, func(s *ServerSpec) { s.Hostname = `web_1` }, []string{`spec.hostname`}},
+		{`hostname without letters`, func(s *ServerSpec) { s.Hostname = `123` }, []string{`spec.hostname`}},
+		{`hostname label too long`, func(s *ServerSpec) { s.Hostname = strings.Repeat(`a`, 64) }, []string{`spec.hostname`}},
+		{`hostname too long`, func(s *ServerSpec) { s.Hostname = strings.Repeat(`abcdefghi.`, 10) + `a` }, []string{`spec.hostname`}},
+		{`description too long`, func(s *ServerSpec) { s.Description = strings.Repeat(`a`, 251) }, []string{`spec.description`}},
+		{`ssh keys`, func(s *ServerSpec) { s.SSHKeyIDs = []string{keyA, keyB} }, nil},
+		{`invalid ssh key`, func(s *ServerSpec) { s.SSHKeyIDs = []string{`my-key`} }, []string{`spec.sshKeyIds[0]`}},
+		{`duplicate ssh key`, func(s *ServerSpec) { s.SSHKeyIDs = []string{keyA, keyA} }, []string{`spec.sshKeyIds[1]`}},
+		{`ip block by name and id`, func(s *ServerSpec) {
+			s.Network = &ServerNetwork{IPBlocks: []ServerIPBlock{{Name: `lb`, ID: keyA}}}
+		}, []string{`spec.network.ipBlocks[0]`}},
+		{`ip block without reference`, func(s *ServerSpec) {
+			s.Network = &ServerNetwork{IPBlocks: []ServerIPBlock{{}}}
+		}, []string{`spec.network.ipBlocks[0]`}},
+		{`invalid ip block id`, func(s *ServerSpec) {
+			s.Network = &ServerNetwork{IPBlocks: []ServerIPBlock{{ID: `block`}}}
+		}, []string{`spec.network.ipBlocks[0].id`}},
+		{`location preferences with ip blocks`, func(s *ServerSpec) {
+			s.Network = &ServerNetwork{IPBlocks: []ServerIPBlock{{Name: `lb`}}}
+			s.LocationPreferences = []LocationID{Ashburn}
+		}, []string{`spec.locationPreferences`}},
+		{`unknown type`, func(s *ServerSpec) { s.Type = `x9.large` }, []string{`spec.type`}},
+		{`type not offered in location`, func(s *ServerSpec) { s.Type, s.Location = D1M1Medium, Singapore }, []string{`spec.type`}},
+		{`os supported by every type`, func(s *ServerSpec) { s.OS = CentosCentos7 }, nil},
+		{`os supported by type`, func(s *ServerSpec) { s.Type = D1M1Medium }, nil},
+		{`os not supported by type`, func(s *ServerSpec) { s.Type, s.OS = D1M1Medium, CentosCentos7 }, []string{`spec.os`}},
+		{`os not supported by type preference`, func(s *ServerSpec) {
+			s.OS = CentosCentos7
+			s.TypePreferences = []ServerType{D1M1Medium}
+		}, []string{`spec.typePreferences[0]`}},
+		{`preferences`, func(s *ServerSpec) {
+			s.TypePreferences = []ServerType{S1C1Medium}
+			s.LocationPreferences = []LocationID{Ashburn}
+		}, nil},
+		{`unknown type preference`, func(s *ServerSpec) { s.TypePreferences = []ServerType{`x9.large`} }, []string{`spec.typePreferences[0]`}},
+		{`duplicate type preference`, func(s *ServerSpec) { s.TypePreferences = []ServerType{S1C1Small} }, []string{`spec.typePreferences[0]`}},
+		{`type preference not offered in any location`, func(s *ServerSpec) {
+			s.Location = Singapore
+			s.TypePreferences = []ServerType{D1M1Medium}
+		}, []string{`spec.typePreferences[0]`}},
+		{`duplicate location preference`, func(s *ServerSpec) { s.LocationPreferences = []LocationID{Phoenix} }, []string{`spec.locationPreferences[0]`}},
+		{`location preference offering no type`, func(s *ServerSpec) {
+			s.Type = D1M1Medium
+			s.LocationPreferences = []LocationID{Singapore}
+		}, []string{`spec.locationPreferences[0]`}},
+		{`unknown location preference`, func(s *ServerSpec) { s.LocationPreferences = []LocationID{`MARS`} }, []string{`spec.locationPreferences[0]`}},
+		{`ttl`, func(s *ServerSpec) { s.TTL = &metav1.Duration{Duration: time.Hour} }, nil},
+		{`zero ttl`, func(s *ServerSpec) { s.TTL = &metav1.Duration{} }, []string{`spec.ttl`}},
+		{`power schedule`, func(s *ServerSpec) { s.PowerSchedule = &PowerSchedule{On: `0 8 * * 1-5`, Off: `0 18 * * 1-5`} }, nil},
+		{`invalid power schedule`, func(s *ServerSpec) { s.PowerSchedule = &PowerSchedule{On: `0 8 * *`, Off: `0 18 * * 1-5`} }, []string{`spec.powerSchedule`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := validSpec()
+			tt.mutate(&spec)
+			got := errorFields(validateServerSpec(&spec, field.NewPath(`spec`)))
+			if strings.Join(got, `,`) != strings.Join(tt.want, `,`) {
+				t.Errorf("validateServerSpec() errors on %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateServerSpecUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*ServerSpec)
+		want   []string
+	}{
+		{`unchanged`, func(s *ServerSpec) {}, nil},
+		{`changed to invalid hostname`, func(s *ServerSpec) { s.Hostname = `web_1` }, []string{`spec.hostname`}},
+		{`changed to unsupported os`, func(s *ServerSpec) { s.Type = D1M1Medium; s.OS = CentosCentos7 }, []string{`spec.os`}},
+		{`changed to unknown type`, func(s *ServerSpec) { s.Type = `x9.large` }, []string{`spec.type`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := validSpec()
+			spec := validSpec()
+			tt.mutate(&spec)
+			got := errorFields(validateServerSpecUpdate(&spec, &prev, field.NewPath(`spec`)))
+			if strings.Join(got, `,`) != strings.Join(tt.want, `,`) {
+				t.Errorf("validateServerSpecUpdate() errors on %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Unchanged fields are not validated again, for example after the type
+	// was withdrawn from the catalog
+	prev := validSpec()
+	prev.Type = `x9.large`
+	spec := prev
+	spec.Description = `renamed`
+	if errs := validateServerSpecUpdate(&spec, &prev, field.NewPath(`spec`)); len(errs) > 0 {
+		t.Errorf("validateServerSpecUpdate() = %v, want no errors for unchanged fields", errs)
+	}
+}
@@ -17,11 +17,17 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -29,7 +35,12 @@ import (
 // log is for logging in this package.
 var serverlog = logf.Log.WithName("server-resource")
 
+// webhookClient is used by the webhooks to read related resources such as
+// ServerClasses. It is set when the webhook is registered with a manager.
+var webhookClient client.Client
+
 func (r *Server) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookClient = mgr.GetClient()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -37,12 +48,37 @@ func (r *Server) SetupWebhookWithManager(mgr ctrl.Manager) error {
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcbudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverquotas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverpolicies,verbs=get;list;watch
+
 // +kubebuilder:webhook:path=/mutate-bmc-api-phoenixnap-com-v1-server,mutating=true,failurePolicy=fail,groups=bmc.api.phoenixnap.com,resources=servers,verbs=create;update,versions=v1,name=mserver.kb.io
 
 var _ webhook.Defaulter = &Server{}
 
 // Default implements webhook.Defaulter so a webhook will be registered for the type
 func (r *Server) Default() {
+	if len(r.Spec.ServerClassName) > 0 {
+		if class, err := r.serverClass(); err != nil {
+			// ValidateCreate reports a missing class
+			serverlog.Info("unable to load server class", "name", r.Name, "serverClassName", r.Spec.ServerClassName, "error", err.Error())
+		} else {
+			class.ApplyTo(&r.Spec)
+		}
+	}
+	// Policies only default new servers so that adding a required SSH key
+	// does not change existing ones.
+	if r.CreationTimestamp.IsZero() {
+		if policies, err := r.serverPolicies(); err != nil {
+			// ValidateCreate reports the error
+			serverlog.Info("unable to load server policies", "name", r.Name, "namespace", r.Namespace, "error", err.Error())
+		} else {
+			for i := range policies {
+				policies[i].ApplyTo(&r.Spec)
+			}
+		}
+	}
 	if r.Spec.OS == `` {
 		r.Spec.OS = UbuntuBionic
 	}
@@ -67,7 +103,29 @@ var _ webhook.Validator = &Server{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *Server) ValidateCreate() error {
-	return nil
+	serverlog.Info("validate create", "name", r.Name)
+
+	allErrs := validateServerSpec(&r.Spec, field.NewPath(`spec`))
+	allErrs = append(allErrs, validateService(r.Spec.Service, r.Name, field.NewPath(`spec`).Child(`service`))...)
+	if len(r.Spec.ServerClassName) > 0 {
+		if _, err := r.serverClass(); apierrors.IsNotFound(err) {
+			allErrs = append(allErrs, field.NotFound(field.NewPath(`spec`).Child(`serverClassName`), r.Spec.ServerClassName))
+		} else if err != nil {
+			allErrs = append(allErrs, field.InternalError(field.NewPath(`spec`).Child(`serverClassName`), err))
+		}
+	}
+	if budget, err := r.blockingBudget(); err != nil {
+		allErrs = append(allErrs, field.InternalError(field.NewPath(`metadata`).Child(`namespace`), err))
+	} else if budget != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath(`metadata`).Child(`namespace`),
+			fmt.Sprintf("BMCBudget %s is exceeded, spent %s USD of %s USD", budget.Name, budget.Status.Spent, budget.Spec.Amount)))
+	}
+	allErrs = append(allErrs, r.policyErrors()...)
+	allErrs = append(allErrs, r.quotaErrors()...)
+	if len(allErrs) <= 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: `bmc.api.phoenixnap.com`, Kind: `Server`}, r.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -75,33 +133,79 @@ func (r *Server) ValidateUpdate(old runtime.Object) error {
 	prev := old.(*Server)
 	serverlog.Info("validate update", "name", r.Name)
 
+	// Deleting servers are only updated to remove finalizers and record status
+	if r.DeletionTimestamp != nil {
+		return nil
+	}
+
 	var allErrs field.ErrorList
-	if r.Spec.Hostname != prev.Spec.Hostname {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`hostname`), `immutable`))
+	if r.Spec.ServerClassName != prev.Spec.ServerClassName {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`serverClassName`), `immutable`))
 	}
-	if r.Spec.Description != prev.Spec.Description {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`description`), `immutable`))
+	if r.Spec.PricingModel != prev.Spec.PricingModel {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`pricingModel`), `immutable`))
 	}
-	if r.Spec.OS != prev.Spec.OS {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`os`), `immutable`))
+	if !reflect.DeepEqual(r.Spec.Network, prev.Spec.Network) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`network`), `immutable`))
 	}
-	if r.Spec.Type != prev.Spec.Type {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`type`), `immutable`))
+
+	// Fields applied by reprovisioning the server
+	reprovisionable := r.Spec.UpdatePolicy == UpdateReprovision || r.Spec.UpdatePolicy == UpdateRecreate
+	if !reprovisionable {
+		if r.Spec.Hostname != prev.Spec.Hostname {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`hostname`), `immutable unless updatePolicy is set`))
+		}
+		if r.Spec.Description != prev.Spec.Description {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`description`), `immutable unless updatePolicy is set`))
+		}
+		if r.Spec.OS != prev.Spec.OS {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`os`), `immutable unless updatePolicy is set`))
+		}
+		if !reflect.DeepEqual(r.Spec.UserDataSecretRef, prev.Spec.UserDataSecretRef) {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`userDataSecretRef`), `immutable unless updatePolicy is set`))
+		}
+		if len(r.Spec.SSHKeyIDs) != len(prev.Spec.SSHKeyIDs) {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`sshKeyIds`), `immutable unless updatePolicy is set`))
+		} else {
+			for i, _ := range r.Spec.SSHKeyIDs {
+				if r.Spec.SSHKeyIDs[i] != prev.Spec.SSHKeyIDs[i] {
+					allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`sshKeyIds`), `immutable unless updatePolicy is set`))
+				}
+			}
+		}
 	}
-	if r.Spec.Location != prev.Spec.Location {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`location`), `immutable`))
+
+	// Fields applied by replacing the server
+	if r.Spec.UpdatePolicy != UpdateRecreate {
+		if r.Spec.Type != prev.Spec.Type {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`type`), `immutable unless updatePolicy is Recreate`))
+		}
+		if r.Spec.Location != prev.Spec.Location {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`location`), `immutable unless updatePolicy is Recreate`))
+		}
+		if r.Spec.NetworkType != prev.Spec.NetworkType {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`networkType`), `immutable unless updatePolicy is Recreate`))
+		}
 	}
-	if r.Spec.NetworkType != prev.Spec.NetworkType {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`networkType`), `immutable`))
+
+	if r.Spec.Location != prev.Spec.Location && r.Spec.Network != nil && len(r.Spec.Network.IPBlocks) > 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`location`), `immutable while network.ipBlocks are attached`))
 	}
-	if len(r.Spec.SSHKeyIDs) != len(prev.Spec.SSHKeyIDs) {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`sshKeyIds`), `immutable`))
-	} else {
-		for i, _ := range r.Spec.SSHKeyIDs {
-			if r.Spec.SSHKeyIDs[i] != prev.Spec.SSHKeyIDs[i] {
-				allErrs = append(allErrs, field.Forbidden(field.NewPath(`spec`).Child(`sshKeyIds`), `immutable`))
-			}
-		}
+
+	allErrs = append(allErrs, validateServerSpecUpdate(&r.Spec, &prev.Spec, field.NewPath(`spec`))...)
+	if !reflect.DeepEqual(r.Spec.Service, prev.Spec.Service) {
+		allErrs = append(allErrs, validateService(r.Spec.Service, r.Name, field.NewPath(`spec`).Child(`service`))...)
+	}
+	if r.Spec.Type != prev.Spec.Type || r.Spec.Location != prev.Spec.Location ||
+		!reflect.DeepEqual(r.Spec.TypePreferences, prev.Spec.TypePreferences) ||
+		!reflect.DeepEqual(r.Spec.LocationPreferences, prev.Spec.LocationPreferences) {
+		allErrs = append(allErrs, r.quotaErrors()...)
+	}
+	if r.Spec.Type != prev.Spec.Type || r.Spec.Location != prev.Spec.Location || r.Spec.OS != prev.Spec.OS || r.Spec.NetworkType != prev.Spec.NetworkType ||
+		!reflect.DeepEqual(r.Spec.SSHKeyIDs, prev.Spec.SSHKeyIDs) ||
+		!reflect.DeepEqual(r.Spec.TypePreferences, prev.Spec.TypePreferences) ||
+		!reflect.DeepEqual(r.Spec.LocationPreferences, prev.Spec.LocationPreferences) {
+		allErrs = append(allErrs, r.policyErrors()...)
 	}
 	if len(allErrs) <= 0 {
 		return nil
@@ -113,3 +217,82 @@ func (r *Server) ValidateUpdate(old runtime.Object) error {
 func (r *Server) ValidateDelete() error {
 	return nil
 }
+
+// serverClass loads the ServerClass named by the server spec.
+func (r *Server) serverClass() (*ServerClass, error) {
+	if webhookClient == nil {
+		return nil, fmt.Errorf(`webhook client is not configured`)
+	}
+	var class ServerClass
+	if err := webhookClient.Get(context.Background(), types.NamespacedName{Name: r.Spec.ServerClassName}, &class); err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// blockingBudget returns an exceeded BMCBudget in the namespace of the server
+// that blocks new servers, or nil when there is none.
+func (r *Server) blockingBudget() (*BMCBudget, error) {
+	if webhookClient == nil {
+		return nil, fmt.Errorf(`webhook client is not configured`)
+	}
+	var budgets BMCBudgetList
+	if err := webhookClient.List(context.Background(), &budgets, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range budgets.Items {
+		if budgets.Items[i].Spec.Action == BudgetBlock && budgets.Items[i].Status.Exceeded {
+			return &budgets.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// quotaErrors checks the server against the ServerQuotas in its namespace.
+func (r *Server) quotaErrors() field.ErrorList {
+	path := field.NewPath(`metadata`).Child(`namespace`)
+	if webhookClient == nil {
+		return field.ErrorList{field.InternalError(path, fmt.Errorf(`webhook client is not configured`))}
+	}
+	var quotas ServerQuotaList
+	if err := webhookClient.List(context.Background(), &quotas, client.InNamespace(r.Namespace)); err != nil {
+		return field.ErrorList{field.InternalError(path, err)}
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+	var servers ServerList
+	if err := webhookClient.List(context.Background(), &servers, client.InNamespace(r.Namespace)); err != nil {
+		return field.ErrorList{field.InternalError(path, err)}
+	}
+	var allErrs field.ErrorList
+	for i := range quotas.Items {
+		allErrs = append(allErrs, quotas.Items[i].Admit(r, servers.Items)...)
+	}
+	return allErrs
+}
+
+// serverPolicies loads the ServerPolicies in the namespace of the server.
+func (r *Server) serverPolicies() ([]ServerPolicy, error) {
+	if webhookClient == nil {
+		return nil, fmt.Errorf(`webhook client is not configured`)
+	}
+	var policies ServerPolicyList
+	if err := webhookClient.List(context.Background(), &policies, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+	return policies.Items, nil
+}
+
+// policyErrors checks the server against the ServerPolicies in its namespace.
+func (r *Server) policyErrors() field.ErrorList {
+	policies, err := r.serverPolicies()
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath(`metadata`).Child(`namespace`), err)}
+	}
+	var allErrs field.ErrorList
+	for i := range policies {
+		allErrs = append(allErrs, policies[i].Validate(&r.Spec, field.NewPath(`spec`))...)
+	}
+	return allErrs
+}
@@ -0,0 +1,87 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServerClaimSpec defines the desired state of ServerClaim
+type ServerClaimSpec struct {
+	// Name of the ServerPool to claim from. Any pool in the namespace is used when empty.
+	// +kubebuilder:validation:Optional
+	PoolName string `json:"poolName,omitempty"`
+
+	// Server type the claimed server must have.
+	// +kubebuilder:validation:Optional
+	Type ServerType `json:"type,omitempty"`
+
+	// Location ID the claimed server must be in.
+	// +kubebuilder:validation:Optional
+	Location LocationID `json:"location,omitempty"`
+
+	// Labels the claimed server must match.
+	// +kubebuilder:validation:Optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ClaimPhase is the binding state of a ServerClaim.
+type ClaimPhase string
+
+const (
+	// ClaimPending is used for claims that are not yet bound.
+	ClaimPending ClaimPhase = `Pending`
+	// ClaimBound is used for claims bound to a server.
+	ClaimBound ClaimPhase = `Bound`
+	// ClaimLost is used for claims whose bound server no longer exists.
+	ClaimLost ClaimPhase = `Lost`
+)
+
+// ServerClaimStatus defines the observed state of ServerClaim
+type ServerClaimStatus struct {
+	Phase ClaimPhase `json:"phase,omitempty"`
+	// Name of the bound server. While the claim is pending it names the
+	// server reserved for the claim.
+	ServerName string `json:"serverName,omitempty"`
+	PoolName   string `json:"poolName,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerClaim is the Schema for the serverclaims API
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.status.serverName`
+type ServerClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerClaimSpec   `json:"spec,omitempty"`
+	Status ServerClaimStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerClaimList contains a list of ServerClaim
+type ServerClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerClaim{}, &ServerClaimList{})
+}
@@ -0,0 +1,62 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *ServerClaim) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-bmc-api-phoenixnap-com-v1-serverclaim,mutating=false,failurePolicy=fail,groups=bmc.api.phoenixnap.com,resources=serverclaims,versions=v1,name=vserverclaim.kb.io
+
+var _ webhook.Validator = &ServerClaim{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ServerClaim) ValidateCreate() error {
+	return r.validate(validateTypeLocation(r.Spec.Type, r.Spec.Location, field.NewPath(`spec`)))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ServerClaim) ValidateUpdate(old runtime.Object) error {
+	prev := old.(*ServerClaim)
+	if r.DeletionTimestamp != nil || (r.Spec.Type == prev.Spec.Type && r.Spec.Location == prev.Spec.Location) {
+		return nil
+	}
+	return r.validate(validateTypeLocation(r.Spec.Type, r.Spec.Location, field.NewPath(`spec`)))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ServerClaim) ValidateDelete() error {
+	return nil
+}
+
+func (r *ServerClaim) validate(allErrs field.ErrorList) error {
+	if len(allErrs) <= 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: `bmc.api.phoenixnap.com`, Kind: `ServerClaim`}, r.Name, allErrs)
+}
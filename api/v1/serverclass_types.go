@@ -0,0 +1,112 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServerClassSpec defines the defaults applied to servers of this class
+type ServerClassSpec struct {
+	// OS ID used for server creation.
+	// +kubebuilder:validation:Optional
+	OS ServerOS `json:"os,omitempty"`
+
+	// Server type used for creation.
+	// +kubebuilder:validation:Optional
+	Type ServerType `json:"type,omitempty"`
+
+	// Location ID where the server is created.
+	// +kubebuilder:validation:Optional
+	Location LocationID `json:"location,omitempty"`
+
+	// Whether or not to install SSH Keys marked as default in addition to any SSH keys specified on the server.
+	// +kubebuilder:validation:Optional
+	InstallDefaultSSHKeys *bool `json:"installDefaultSshKeys,omitempty"`
+
+	// A list of SSH key IDs (BMC resource ID) that will be installed on servers of this class.
+	// Used only when the server does not list any SSH key IDs itself.
+	// +kubebuilder:validation:Optional
+	SSHKeyIDs []string `json:"sshKeyIds,omitempty"`
+
+	// The type of networks where servers of this class should be attached.
+	// +kubebuilder:validation:Optional
+	NetworkType NetworkType `json:"networkType,omitempty"`
+
+	// Tags assigned to servers of this class. Tags set on the server take precedence.
+	// +kubebuilder:validation:Optional
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// ServerClass is the Schema for the serverclasses API
+// +kubebuilder:printcolumn:name="OS",type=string,JSONPath=`.spec.os`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.spec.location`
+type ServerClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServerClassSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerClassList contains a list of ServerClass
+type ServerClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerClass{}, &ServerClassList{})
+}
+
+// ApplyTo fills unset fields of the server spec with the values of this class.
+func (c *ServerClass) ApplyTo(spec *ServerSpec) {
+	if spec.OS == `` {
+		spec.OS = c.Spec.OS
+	}
+	if spec.Type == `` {
+		spec.Type = c.Spec.Type
+	}
+	if spec.Location == `` {
+		spec.Location = c.Spec.Location
+	}
+	if spec.NetworkType == `` {
+		spec.NetworkType = c.Spec.NetworkType
+	}
+	if spec.InstallDefaultSSHKeys == nil && c.Spec.InstallDefaultSSHKeys != nil {
+		spec.InstallDefaultSSHKeys = new(bool)
+		*spec.InstallDefaultSSHKeys = *c.Spec.InstallDefaultSSHKeys
+	}
+	if len(spec.SSHKeyIDs) == 0 && len(c.Spec.SSHKeyIDs) > 0 {
+		spec.SSHKeyIDs = append([]string{}, c.Spec.SSHKeyIDs...)
+	}
+	for k, v := range c.Spec.Tags {
+		if _, ok := spec.Tags[k]; ok {
+			continue
+		}
+		if spec.Tags == nil {
+			spec.Tags = map[string]string{}
+		}
+		spec.Tags[k] = v
+	}
+}
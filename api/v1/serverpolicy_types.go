@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ServerPolicySpec defines the server settings allowed in a namespace. Empty
+// lists allow any value. The first entry of each list is the default for
+// servers that do not set the field.
+type ServerPolicySpec struct {
+	// Server types servers in the namespace may use.
+	// +kubebuilder:validation:Optional
+	AllowedTypes []ServerType `json:"allowedTypes,omitempty"`
+
+	// Locations servers in the namespace may use.
+	// +kubebuilder:validation:Optional
+	AllowedLocations []LocationID `json:"allowedLocations,omitempty"`
+
+	// OS images servers in the namespace may use.
+	// +kubebuilder:validation:Optional
+	AllowedOS []ServerOS `json:"allowedOs,omitempty"`
+
+	// Network types servers in the namespace may use.
+	// +kubebuilder:validation:Optional
+	AllowedNetworkTypes []NetworkType `json:"allowedNetworkTypes,omitempty"`
+
+	// SSH key IDs (BMC resource ID) that must be installed on every server in the namespace.
+	// They are added to servers that do not list them.
+	// +kubebuilder:validation:Optional
+	RequiredSSHKeyIDs []string `json:"requiredSshKeyIds,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=serverpolicies
+
+// ServerPolicy is the Schema for the serverpolicies API
+// +kubebuilder:printcolumn:name="Types",type=string,JSONPath=`.spec.allowedTypes`
+// +kubebuilder:printcolumn:name="Locations",type=string,JSONPath=`.spec.allowedLocations`
+// +kubebuilder:printcolumn:name="OS",type=string,JSONPath=`.spec.allowedOs`
+type ServerPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServerPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerPolicyList contains a list of ServerPolicy
+type ServerPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerPolicy{}, &ServerPolicyList{})
+}
+
+// ApplyTo fills unset fields of the server spec with the first allowed value
+// of this policy and adds the required SSH keys.
+func (p *ServerPolicy) ApplyTo(spec *ServerSpec) {
+	if spec.Type == `` && len(p.Spec.AllowedTypes) > 0 {
+		spec.Type = p.Spec.AllowedTypes[0]
+	}
+	if spec.Location == `` && len(p.Spec.AllowedLocations) > 0 {
+		spec.Location = p.Spec.AllowedLocations[0]
+	}
+	if spec.OS == `` && len(p.Spec.AllowedOS) > 0 {
+		spec.OS = p.Spec.AllowedOS[0]
+	}
+	if spec.NetworkType == `` && len(p.Spec.AllowedNetworkTypes) > 0 {
+		spec.NetworkType = p.Spec.AllowedNetworkTypes[0]
+	}
+	for _, id := range p.Spec.RequiredSSHKeyIDs {
+		if !containsString(spec.SSHKeyIDs, id) {
+			spec.SSHKeyIDs = append(spec.SSHKeyIDs, id)
+		}
+	}
+}
+
+// Validate checks the server spec against this policy.
+func (p *ServerPolicy) Validate(spec *ServerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(p.Spec.AllowedTypes) > 0 {
+		if !containsServerType(p.Spec.AllowedTypes, spec.Type) {
+			allErrs = append(allErrs, field.Forbidden(path.Child(`type`), fmt.Sprintf("ServerPolicy %s allows types %v", p.Name, p.Spec.AllowedTypes)))
+		}
+		for i, t := range spec.TypePreferences {
+			if !containsServerType(p.Spec.AllowedTypes, t) {
+				allErrs = append(allErrs, field.Forbidden(path.Child(`typePreferences`).Index(i), fmt.Sprintf("ServerPolicy %s allows types %v", p.Name, p.Spec.AllowedTypes)))
+			}
+		}
+	}
+	if len(p.Spec.AllowedLocations) > 0 {
+		if !containsLocation(p.Spec.AllowedLocations, spec.Location) {
+			allErrs = append(allErrs, field.Forbidden(path.Child(`location`), fmt.Sprintf("ServerPolicy %s allows locations %v", p.Name, p.Spec.AllowedLocations)))
+		}
+		for i, l := range spec.LocationPreferences {
+			if !containsLocation(p.Spec.AllowedLocations, l) {
+				allErrs = append(allErrs, field.Forbidden(path.Child(`locationPreferences`).Index(i), fmt.Sprintf("ServerPolicy %s allows locations %v", p.Name, p.Spec.AllowedLocations)))
+			}
+		}
+	}
+	if len(p.Spec.AllowedOS) > 0 && !containsOS(p.Spec.AllowedOS, spec.OS) {
+		allErrs = append(allErrs, field.Forbidden(path.Child(`os`), fmt.Sprintf("ServerPolicy %s allows OS images %v", p.Name, p.Spec.AllowedOS)))
+	}
+	if len(p.Spec.AllowedNetworkTypes) > 0 && !containsNetworkType(p.Spec.AllowedNetworkTypes, spec.NetworkType) {
+		allErrs = append(allErrs, field.Forbidden(path.Child(`networkType`), fmt.Sprintf("ServerPolicy %s allows network types %v", p.Name, p.Spec.AllowedNetworkTypes)))
+	}
+	for _, id := range p.Spec.RequiredSSHKeyIDs {
+		if !containsString(spec.SSHKeyIDs, id) {
+			allErrs = append(allErrs, field.Required(path.Child(`sshKeyIds`), fmt.Sprintf("ServerPolicy %s requires SSH key %s", p.Name, id)))
+		}
+	}
+	return allErrs
+}
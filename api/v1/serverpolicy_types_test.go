@@ -0,0 +1,152 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	policyKeyA = `5fa54d1e91867c03a0a7b4a4`
+	policyKeyB = `5fa54d1e91867c03a0a7b4a5`
+)
+
+var testPolicy = ServerPolicy{Spec: ServerPolicySpec{
+	AllowedTypes:        []ServerType{S1C1Medium, S1C1Small},
+	AllowedLocations:    []LocationID{Ashburn, Phoenix},
+	AllowedOS:           []ServerOS{UbuntuBionic},
+	AllowedNetworkTypes: []NetworkType{PrivateOnly},
+	RequiredSSHKeyIDs:   []string{policyKeyA},
+}}
+
+func TestServerPolicyApplyTo(t *testing.T) {
+	tests := []struct {
+		name string
+		spec ServerSpec
+		want ServerSpec
+	}{
+		{
+			name: `defaults`,
+			want: ServerSpec{
+				Type:        S1C1Medium,
+				Location:    Ashburn,
+				OS:          UbuntuBionic,
+				NetworkType: PrivateOnly,
+				SSHKeyIDs:   []string{policyKeyA},
+			},
+		},
+		{
+			name: `set fields are kept`,
+			spec: ServerSpec{
+				Type:        S1C1Small,
+				Location:    Phoenix,
+				OS:          CentosCentos7,
+				NetworkType: PublicAndPrivate,
+				SSHKeyIDs:   []string{policyKeyB},
+			},
+			want: ServerSpec{
+				Type:        S1C1Small,
+				Location:    Phoenix,
+				OS:          CentosCentos7,
+				NetworkType: PublicAndPrivate,
+				SSHKeyIDs:   []string{policyKeyB, policyKeyA},
+			},
+		},
+		{
+			name: `required key not duplicated`,
+			spec: ServerSpec{
+				Type:        S1C1Small,
+				Location:    Phoenix,
+				OS:          UbuntuBionic,
+				NetworkType: PrivateOnly,
+				SSHKeyIDs:   []string{policyKeyA},
+			},
+			want: ServerSpec{
+				Type:        S1C1Small,
+				Location:    Phoenix,
+				OS:          UbuntuBionic,
+				NetworkType: PrivateOnly,
+				SSHKeyIDs:   []string{policyKeyA},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			testPolicy.ApplyTo(&spec)
+			if !reflect.DeepEqual(spec, tt.want) {
+				t.Errorf("ApplyTo() = %+v, want %+v", spec, tt.want)
+			}
+		})
+	}
+
+	// an empty policy changes nothing
+	spec := ServerSpec{}
+	(&ServerPolicy{}).ApplyTo(&spec)
+	if !reflect.DeepEqual(spec, ServerSpec{}) {
+		t.Errorf("ApplyTo() of an empty policy = %+v, want an empty spec", spec)
+	}
+}
+
+func TestServerPolicyValidate(t *testing.T) {
+	valid := func() ServerSpec {
+		return ServerSpec{
+			Type:        S1C1Small,
+			Location:    Phoenix,
+			OS:          UbuntuBionic,
+			NetworkType: PrivateOnly,
+			SSHKeyIDs:   []string{policyKeyB, policyKeyA},
+		}
+	}
+	tests := []struct {
+		name   string
+		mutate func(*ServerSpec)
+		want   []string
+	}{
+		{`allowed`, func(s *ServerSpec) {}, nil},
+		{`type`, func(s *ServerSpec) { s.Type = D1C4Large }, []string{`spec.type`}},
+		{`type preference`, func(s *ServerSpec) {
+			s.TypePreferences = []ServerType{S1C1Medium, D1C4Large}
+		}, []string{`spec.typePreferences[1]`}},
+		{`location`, func(s *ServerSpec) { s.Location = Singapore }, []string{`spec.location`}},
+		{`location preference`, func(s *ServerSpec) {
+			s.LocationPreferences = []LocationID{Singapore}
+		}, []string{`spec.locationPreferences[0]`}},
+		{`os`, func(s *ServerSpec) { s.OS = CentosCentos7 }, []string{`spec.os`}},
+		{`network type`, func(s *ServerSpec) { s.NetworkType = PublicAndPrivate }, []string{`spec.networkType`}},
+		{`missing ssh key`, func(s *ServerSpec) { s.SSHKeyIDs = []string{policyKeyB} }, []string{`spec.sshKeyIds`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := valid()
+			tt.mutate(&spec)
+			got := errorFields(testPolicy.Validate(&spec, field.NewPath(`spec`)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() errors on %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// an empty policy allows anything
+	spec := ServerSpec{Type: D1C4Large, Location: Singapore}
+	if errs := (&ServerPolicy{}).Validate(&spec, field.NewPath(`spec`)); len(errs) > 0 {
+		t.Errorf("Validate() of an empty policy = %v, want no errors", errs)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServerPoolSpec defines the desired state of ServerPool
+type ServerPoolSpec struct {
+	// Number of idle, unclaimed servers to keep provisioned.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Required
+	Size int32 `json:"size"`
+
+	// Template describing the servers that will be created. The hostname is used as a prefix.
+	// +kubebuilder:validation:Required
+	Template ServerTemplateSpec `json:"template"`
+}
+
+// ServerPoolStatus defines the observed state of ServerPool
+type ServerPoolStatus struct {
+	// Number of unclaimed servers in the pool.
+	Idle int32 `json:"idle"`
+	// Number of unclaimed servers that are powered on and can be bound immediately.
+	Ready int32 `json:"ready"`
+	// Number of servers that were taken from this pool and are bound to a claim.
+	Claimed int32 `json:"claimed"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerPool is the Schema for the serverpools API
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.spec.size`
+// +kubebuilder:printcolumn:name="Idle",type=integer,JSONPath=`.status.idle`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Claimed",type=integer,JSONPath=`.status.claimed`
+type ServerPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerPoolSpec   `json:"spec,omitempty"`
+	Status ServerPoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerPoolList contains a list of ServerPool
+type ServerPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerPool{}, &ServerPoolList{})
+}
@@ -0,0 +1,241 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ServerQuotaSpec defines the limits on the servers in a namespace. Unset
+// limits are not enforced.
+type ServerQuotaSpec struct {
+	// Maximum number of servers in the namespace.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	MaxServers *int32 `json:"maxServers,omitempty"`
+
+	// Maximum number of CPU cores of all servers in the namespace.
+	// Servers of types with unknown capacity are not counted.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	MaxCores *int32 `json:"maxCores,omitempty"`
+
+	// Maximum memory of all servers in the namespace, in GB.
+	// Servers of types with unknown capacity are not counted.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	MaxMemoryGB *int32 `json:"maxMemoryGB,omitempty"`
+
+	// Limits on the number of servers of a type, in a location, or of a type in a location.
+	// +kubebuilder:validation:Optional
+	Limits []ServerQuotaLimit `json:"limits,omitempty"`
+}
+
+// ServerQuotaLimit limits the number of servers matching a type and location.
+// An empty type or location matches any.
+type ServerQuotaLimit struct {
+	// +kubebuilder:validation:Optional
+	Type ServerType `json:"type,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Location LocationID `json:"location,omitempty"`
+
+	// Maximum number of matching servers.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Required
+	Max int32 `json:"max"`
+}
+
+// ServerQuotaLimitStatus reports the usage of a limit.
+type ServerQuotaLimitStatus struct {
+	ServerQuotaLimit `json:",inline"`
+
+	// Number of matching servers.
+	Used int32 `json:"used"`
+}
+
+// ServerQuotaStatus defines the observed usage of the namespace
+type ServerQuotaStatus struct {
+	// Number of servers in the namespace.
+	Servers int32 `json:"servers"`
+	// CPU cores of the servers in the namespace.
+	Cores int32 `json:"cores"`
+	// Memory of the servers in the namespace, in GB.
+	MemoryGB int32 `json:"memoryGB"`
+	// Usage of each limit in the spec.
+	Limits []ServerQuotaLimitStatus `json:"limits,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=serverquotas
+
+// ServerQuota is the Schema for the serverquotas API
+// +kubebuilder:printcolumn:name="Servers",type=integer,JSONPath=`.status.servers`
+// +kubebuilder:printcolumn:name="Max Servers",type=integer,JSONPath=`.spec.maxServers`
+// +kubebuilder:printcolumn:name="Cores",type=integer,JSONPath=`.status.cores`
+// +kubebuilder:printcolumn:name="Memory GB",type=integer,JSONPath=`.status.memoryGB`
+type ServerQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerQuotaSpec   `json:"spec,omitempty"`
+	Status ServerQuotaStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerQuotaList contains a list of ServerQuota
+type ServerQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerQuota{}, &ServerQuotaList{})
+}
+
+// Matches reports whether a server of type t in location l counts against the limit.
+func (l *ServerQuotaLimit) Matches(t ServerType, loc LocationID) bool {
+	return (l.Type == `` || l.Type == t) && (l.Location == `` || l.Location == loc)
+}
+
+// String describes the servers matching the limit.
+func (l *ServerQuotaLimit) String() string {
+	switch {
+	case l.Type != `` && l.Location != ``:
+		return fmt.Sprintf("%s servers in %s", l.Type, l.Location)
+	case l.Type != ``:
+		return fmt.Sprintf("%s servers", l.Type)
+	case l.Location != ``:
+		return fmt.Sprintf("servers in %s", l.Location)
+	}
+	return `servers`
+}
+
+// quotaPlacement returns the type and location a server counts against. The
+// placement chosen by the controller takes precedence over the spec.
+func quotaPlacement(s *Server) (ServerType, LocationID) {
+	t, l := s.Spec.Type, s.Spec.Location
+	if s.Status.Type != `` {
+		t = s.Status.Type
+	}
+	if s.Status.Location != `` {
+		l = s.Status.Location
+	}
+	return t, l
+}
+
+// Usage computes the usage of the quota by the servers.
+func (q *ServerQuota) Usage(servers []Server) ServerQuotaStatus {
+	status := ServerQuotaStatus{}
+	for _, limit := range q.Spec.Limits {
+		status.Limits = append(status.Limits, ServerQuotaLimitStatus{ServerQuotaLimit: limit})
+	}
+	for i := range servers {
+		t, l := quotaPlacement(&servers[i])
+		status.Servers++
+		if c, ok := t.Capacity(); ok {
+			status.Cores += c.Cores
+			status.MemoryGB += c.MemoryGB
+		}
+		for j := range status.Limits {
+			if status.Limits[j].Matches(t, l) {
+				status.Limits[j].Used++
+			}
+		}
+	}
+	return status
+}
+
+// quotaCandidate is a type and location a server may be placed in.
+// +kubebuilder:object:generate=false
+type quotaCandidate struct {
+	Type     ServerType
+	Location LocationID
+}
+
+// quotaCandidates returns every type and location the server may be placed
+// in according to its spec and preferences.
+func quotaCandidates(spec *ServerSpec) []quotaCandidate {
+	types := append([]ServerType{spec.Type}, spec.TypePreferences...)
+	locations := append([]LocationID{spec.Location}, spec.LocationPreferences...)
+	var candidates []quotaCandidate
+	seen := map[quotaCandidate]bool{}
+	for _, l := range locations {
+		for _, t := range types {
+			c := quotaCandidate{Type: t, Location: l}
+			if !seen[c] {
+				seen[c] = true
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	return candidates
+}
+
+// Admit checks whether the server fits in the quota next to the other
+// servers of the namespace. The server must fit wherever its preferences
+// may place it. A previous version of the server in servers is ignored.
+func (q *ServerQuota) Admit(server *Server, servers []Server) field.ErrorList {
+	var others []Server
+	for i := range servers {
+		if servers[i].Name != server.Name {
+			others = append(others, servers[i])
+		}
+	}
+	usage := q.Usage(others)
+
+	var allErrs field.ErrorList
+	if q.Spec.MaxServers != nil && usage.Servers+1 > *q.Spec.MaxServers {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath(`metadata`).Child(`namespace`),
+			fmt.Sprintf("ServerQuota %s allows %d servers, %d are in use", q.Name, *q.Spec.MaxServers, usage.Servers)))
+	}
+	seen := map[string]bool{}
+	add := func(path *field.Path, detail string) {
+		if !seen[detail] {
+			seen[detail] = true
+			allErrs = append(allErrs, field.Forbidden(path, detail))
+		}
+	}
+	for _, c := range quotaCandidates(&server.Spec) {
+		t, l := c.Type, c.Location
+		capacity, _ := t.Capacity()
+		if q.Spec.MaxCores != nil && capacity.Cores > 0 && usage.Cores+capacity.Cores > *q.Spec.MaxCores {
+			add(field.NewPath(`spec`).Child(`type`),
+				fmt.Sprintf("ServerQuota %s allows %d cores, %d are in use and %s has %d", q.Name, *q.Spec.MaxCores, usage.Cores, t, capacity.Cores))
+		}
+		if q.Spec.MaxMemoryGB != nil && capacity.MemoryGB > 0 && usage.MemoryGB+capacity.MemoryGB > *q.Spec.MaxMemoryGB {
+			add(field.NewPath(`spec`).Child(`type`),
+				fmt.Sprintf("ServerQuota %s allows %d GB of memory, %d GB are in use and %s has %d GB", q.Name, *q.Spec.MaxMemoryGB, usage.MemoryGB, t, capacity.MemoryGB))
+		}
+		for _, limit := range usage.Limits {
+			if !limit.Matches(t, l) || limit.Used+1 <= limit.Max {
+				continue
+			}
+			path := field.NewPath(`spec`).Child(`type`)
+			if limit.Type == `` {
+				path = field.NewPath(`spec`).Child(`location`)
+			}
+			add(path, fmt.Sprintf("ServerQuota %s allows %d %s, %d are in use", q.Name, limit.Max, limit.String(), limit.Used))
+		}
+	}
+	return allErrs
+}
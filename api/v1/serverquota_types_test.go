@@ -0,0 +1,198 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func quotaServer(name string, t ServerType, l LocationID) Server {
+	return Server{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       ServerSpec{Type: t, Location: l},
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestServerQuotaUsage(t *testing.T) {
+	quota := ServerQuota{Spec: ServerQuotaSpec{
+		Limits: []ServerQuotaLimit{
+			{Type: S1C1Small, Max: 5},
+			{Location: Ashburn, Max: 5},
+			{Type: S1C1Small, Location: Phoenix, Max: 5},
+		},
+	}}
+	placed := quotaServer(`placed`, S1C1Small, Phoenix)
+	placed.Status.Type, placed.Status.Location = S1C1Medium, Ashburn
+
+	tests := []struct {
+		name    string
+		servers []Server
+		want    ServerQuotaStatus
+	}{
+		{
+			name: `no servers`,
+			want: ServerQuotaStatus{Limits: []ServerQuotaLimitStatus{
+				{ServerQuotaLimit: quota.Spec.Limits[0]},
+				{ServerQuotaLimit: quota.Spec.Limits[1]},
+				{ServerQuotaLimit: quota.Spec.Limits[2]},
+			}},
+		},
+		{
+			name: `spec placement`,
+			servers: []Server{
+				quotaServer(`a`, S1C1Small, Phoenix),
+				quotaServer(`b`, S1C1Small, Ashburn),
+				quotaServer(`c`, `x9.large`, Phoenix),
+			},
+			want: ServerQuotaStatus{Servers: 3, Cores: 8, MemoryGB: 64, Limits: []ServerQuotaLimitStatus{
+				{ServerQuotaLimit: quota.Spec.Limits[0], Used: 2},
+				{ServerQuotaLimit: quota.Spec.Limits[1], Used: 1},
+				{ServerQuotaLimit: quota.Spec.Limits[2], Used: 1},
+			}},
+		},
+		{
+			name:    `status placement`,
+			servers: []Server{placed},
+			want: ServerQuotaStatus{Servers: 1, Cores: 8, MemoryGB: 64, Limits: []ServerQuotaLimitStatus{
+				{ServerQuotaLimit: quota.Spec.Limits[0]},
+				{ServerQuotaLimit: quota.Spec.Limits[1], Used: 1},
+				{ServerQuotaLimit: quota.Spec.Limits[2]},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quota.Usage(tt.servers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerQuotaAdmit(t *testing.T) {
+	existing := []Server{
+		quotaServer(`a`, S1C1Small, Phoenix),
+		quotaServer(`b`, S1C1Small, Ashburn),
+	}
+	tests := []struct {
+		name   string
+		spec   ServerQuotaSpec
+		server Server
+		want   []string
+	}{
+		{
+			name:   `unlimited`,
+			server: quotaServer(`new`, D1C4Large, Phoenix),
+		},
+		{
+			name:   `max servers`,
+			spec:   ServerQuotaSpec{MaxServers: int32Ptr(2)},
+			server: quotaServer(`new`, S1C1Small, Phoenix),
+			want:   []string{`metadata.namespace`},
+		},
+		{
+			name:   `update of an existing server`,
+			spec:   ServerQuotaSpec{MaxServers: int32Ptr(2), MaxCores: int32Ptr(12)},
+			server: quotaServer(`a`, S1C1Medium, Phoenix),
+		},
+		{
+			name:   `cores`,
+			spec:   ServerQuotaSpec{MaxCores: int32Ptr(12)},
+			server: quotaServer(`new`, S1C1Medium, Phoenix),
+			want:   []string{`spec.type`},
+		},
+		{
+			name:   `memory`,
+			spec:   ServerQuotaSpec{MaxMemoryGB: int32Ptr(96)},
+			server: quotaServer(`new`, S1C1Small, Phoenix),
+		},
+		{
+			name:   `memory exceeded`,
+			spec:   ServerQuotaSpec{MaxMemoryGB: int32Ptr(96)},
+			server: quotaServer(`new`, S1C1Medium, Phoenix),
+			want:   []string{`spec.type`},
+		},
+		{
+			name:   `unknown capacity`,
+			spec:   ServerQuotaSpec{MaxCores: int32Ptr(8)},
+			server: quotaServer(`new`, `x9.large`, Phoenix),
+		},
+		{
+			name:   `type limit`,
+			spec:   ServerQuotaSpec{Limits: []ServerQuotaLimit{{Type: S1C1Small, Max: 2}}},
+			server: quotaServer(`new`, S1C1Small, Singapore),
+			want:   []string{`spec.type`},
+		},
+		{
+			name:   `location limit`,
+			spec:   ServerQuotaSpec{Limits: []ServerQuotaLimit{{Location: Phoenix, Max: 1}}},
+			server: quotaServer(`new`, S1C1Medium, Phoenix),
+			want:   []string{`spec.location`},
+		},
+		{
+			name:   `limit for another location`,
+			spec:   ServerQuotaSpec{Limits: []ServerQuotaLimit{{Type: S1C1Small, Location: Phoenix, Max: 1}}},
+			server: quotaServer(`new`, S1C1Small, Singapore),
+		},
+		{
+			name: `type preference`,
+			spec: ServerQuotaSpec{MaxCores: int32Ptr(12)},
+			server: func() Server {
+				s := quotaServer(`new`, S1C1Small, Phoenix)
+				s.Spec.TypePreferences = []ServerType{S1C1Medium}
+				return s
+			}(),
+			want: []string{`spec.type`},
+		},
+		{
+			name: `location preference`,
+			spec: ServerQuotaSpec{Limits: []ServerQuotaLimit{{Location: Ashburn, Max: 1}}},
+			server: func() Server {
+				s := quotaServer(`new`, S1C1Small, Phoenix)
+				s.Spec.LocationPreferences = []LocationID{Ashburn}
+				return s
+			}(),
+			want: []string{`spec.location`},
+		},
+		{
+			name: `error reported once for all candidates`,
+			spec: ServerQuotaSpec{Limits: []ServerQuotaLimit{{Type: S1C1Small, Max: 2}}},
+			server: func() Server {
+				s := quotaServer(`new`, S1C1Small, Phoenix)
+				s.Spec.LocationPreferences = []LocationID{Ashburn, Singapore}
+				return s
+			}(),
+			want: []string{`spec.type`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := ServerQuota{ObjectMeta: metav1.ObjectMeta{Name: `quota`}, Spec: tt.spec}
+			got := errorFields(quota.Admit(&tt.server, existing))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Admit() errors on %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
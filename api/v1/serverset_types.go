@@ -0,0 +1,143 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ServerSetSpec defines the desired state of ServerSet
+type ServerSetSpec struct {
+	// Number of servers to maintain. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Pattern used to derive the hostname of each server. The string {index} is
+	// replaced with the replica index. Defaults to the template hostname followed by -{index}.
+	// +kubebuilder:validation:MaxLength=100
+	// +kubebuilder:validation:Optional
+	HostnamePattern string `json:"hostnamePattern,omitempty"`
+
+	// Order in which servers are removed when scaling down. Servers that are not
+	// powered on are always removed first. Defaults to Newest.
+	// +kubebuilder:validation:Optional
+	DeletionOrder DeletionOrder `json:"deletionOrder,omitempty"`
+
+	// Strategy used to replace existing servers when the template changes.
+	// +kubebuilder:validation:Optional
+	Strategy ServerSetStrategy `json:"strategy,omitempty"`
+
+	// Template describing the servers that will be created.
+	// +kubebuilder:validation:Required
+	Template ServerTemplateSpec `json:"template"`
+}
+
+// ServerTemplateSpec describes the servers created by a ServerSet.
+type ServerTemplateSpec struct {
+	// Labels and annotations applied to created servers.
+	// +kubebuilder:validation:Optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Specification of created servers. The hostname is derived from the hostname pattern of the set.
+	Spec ServerSpec `json:"spec"`
+}
+
+// ServerSetStrategy describes how servers are replaced when the template changes.
+type ServerSetStrategy struct {
+	// Type of replacement. Defaults to OnDelete.
+	// +kubebuilder:validation:Optional
+	Type ServerSetStrategyType `json:"type,omitempty"`
+
+	// Parameters for the RollingReplace strategy.
+	// +kubebuilder:validation:Optional
+	RollingReplace *RollingReplaceServerSet `json:"rollingReplace,omitempty"`
+}
+
+// ServerSetStrategyType is the type of replacement strategy of a ServerSet.
+// Only one of the following strategies may be specified.
+// If none of the following strategies are specified, the default one is OnDelete.
+// +kubebuilder:validation:Enum=OnDelete;RollingReplace
+type ServerSetStrategyType string
+
+const (
+	// OnDeleteStrategy applies template changes only to servers created after the change.
+	OnDeleteStrategy ServerSetStrategyType = `OnDelete`
+	// RollingReplaceStrategy provisions new servers and deletes outdated ones once the new ones are powered on.
+	RollingReplaceStrategy ServerSetStrategyType = `RollingReplace`
+)
+
+// RollingReplaceServerSet controls the pace of a rolling replacement.
+type RollingReplaceServerSet struct {
+	// Maximum number of servers that can be provisioned above the desired number of replicas.
+	// Value can be an absolute number or a percentage of desired replicas. Defaults to 1.
+	// +kubebuilder:validation:Optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// Maximum number of servers that can be unavailable during the replacement.
+	// Value can be an absolute number or a percentage of desired replicas. Defaults to 0.
+	// +kubebuilder:validation:Optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// DeletionOrder selects which servers a ServerSet removes first when scaling down.
+// Only one of the following orders may be specified.
+// If none of the following orders are specified, the default one is Newest.
+// +kubebuilder:validation:Enum=Newest;Oldest;HighestIndex
+type DeletionOrder string
+
+const (
+	DeleteNewest       DeletionOrder = `Newest`
+	DeleteOldest       DeletionOrder = `Oldest`
+	DeleteHighestIndex DeletionOrder = `HighestIndex`
+)
+
+// ServerSetStatus defines the observed state of ServerSet
+type ServerSetStatus struct {
+	Replicas        int32 `json:"replicas"`
+	ReadyReplicas   int32 `json:"readyReplicas"`
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerSet is the Schema for the serversets API
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.spec.replicas`
+// +kubebuilder:printcolumn:name="Current",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Up-to-date",type=integer,JSONPath=`.status.updatedReplicas`
+type ServerSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerSetSpec   `json:"spec,omitempty"`
+	Status ServerSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServerSetList contains a list of ServerSet
+type ServerSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerSet{}, &ServerSetList{})
+}
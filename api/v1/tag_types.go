@@ -0,0 +1,83 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TagSpec defines the desired state of Tag
+type TagSpec struct {
+	// Name of the BMC tag. Defaults to the name of this resource.
+	// +kubebuilder:validation:MaxLength=100
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Description of the tag.
+	// +kubebuilder:validation:MaxLength=250
+	// +kubebuilder:validation:Optional
+	Description string `json:"description,omitempty"`
+
+	// Whether or not to show the tag as part of billing statements.
+	// +kubebuilder:validation:Optional
+	IsBillingTag bool `json:"isBillingTag,omitempty"`
+}
+
+// TagStatus defines the observed state of Tag
+type TagStatus struct {
+	BMCTagID     string   `json:"id,omitempty"`
+	BMCStatus    string   `json:"status,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	IsBillingTag bool     `json:"isBillingTag,omitempty"`
+	Values       []string `json:"values,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// Tag is the Schema for the tags API
+// +kubebuilder:printcolumn:name="Billing",type=boolean,JSONPath=`.spec.isBillingTag`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+type Tag struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TagSpec   `json:"spec,omitempty"`
+	Status TagStatus `json:"status,omitempty"`
+}
+
+// TagName returns the BMC name of the tag.
+func (t *Tag) TagName() string {
+	if len(t.Spec.Name) > 0 {
+		return t.Spec.Name
+	}
+	return t.Name
+}
+
+// +kubebuilder:object:root=true
+
+// TagList contains a list of Tag
+type TagList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Tag `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Tag{}, &TagList{})
+}
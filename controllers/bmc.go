@@ -0,0 +1,152 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2/clientcredentials"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	ENV_BMC_IPS_ENDPOINT_URL  = `BMC_IPS_ENDPOINT_URL`
+	ENV_BMC_TAGS_ENDPOINT_URL = `BMC_TAGS_ENDPOINT_URL`
+
+	defaultIPsEndpointURL  = `https://api.phoenixnap.com/ips/v1/`
+	defaultTagsEndpointURL = `https://api.phoenixnap.com/tag-manager/v1/`
+)
+
+// bmcClient returns an HTTP client authenticated with the BMC client
+// credentials from the environment. main verifies that the configuration is
+// complete before any reconciler is started. Scopes default to the BMC API
+// scopes when none are given.
+func bmcClient(ctx context.Context, scopes ...string) *http.Client {
+	if len(scopes) == 0 {
+		scopes = []string{"bmc", "bmc.read"}
+	}
+	bmcConfig := clientcredentials.Config{
+		ClientID:     os.Getenv(ENV_BMC_CLIENT_ID),
+		ClientSecret: os.Getenv(ENV_BMC_CLIENT_SECRET),
+		TokenURL:     os.Getenv(ENV_BMC_TOKEN_URL),
+		Scopes:       scopes}
+	return bmcConfig.Client(ctx)
+}
+
+// endpointURL returns the value of the named environment variable or def when
+// it is not set. Values are expected to end with a trailing slash.
+func endpointURL(env, def string) string {
+	if v := os.Getenv(env); len(v) > 0 {
+		return v
+	}
+	return def
+}
+
+// serverRecord is a BMC server as returned by the servers API, including the
+// provisioning fields that are not part of ServerStatus.
+type serverRecord struct {
+	bmcv1.ServerStatus
+	Hostname    string            `json:"hostname"`
+	Description string            `json:"description"`
+	OS          bmcv1.ServerOS    `json:"os"`
+	NetworkType bmcv1.NetworkType `json:"networkType"`
+
+	// SSH keys are only set when the servers API reports them.
+	SSHKeyIDs             []string `json:"sshKeyIds,omitempty"`
+	InstallDefaultSSHKeys *bool    `json:"installDefaultSshKeys,omitempty"`
+}
+
+// serverCredentials holds the one-time credentials returned by a BMC server
+// create call for some OS images.
+type serverCredentials struct {
+	Password        string `json:"password,omitempty"`
+	OSConfiguration struct {
+		RootPassword    string `json:"rootPassword,omitempty"`
+		ManagementUIURL string `json:"managementUiUrl,omitempty"`
+	} `json:"osConfiguration,omitempty"`
+}
+
+// serverCreateRequest is the body of a BMC server create call.
+type serverCreateRequest struct {
+	Hostname              string                   `json:"hostname"`
+	Description           string                   `json:"description,omitempty"`
+	OS                    bmcv1.ServerOS           `json:"os"`
+	Type                  bmcv1.ServerType         `json:"type"`
+	Location              bmcv1.LocationID         `json:"location"`
+	InstallDefaultSSHKeys *bool                    `json:"installDefaultSshKeys,omitempty"`
+	SSHKeyIDs             []string                 `json:"sshKeyIds,omitempty"`
+	NetworkType           bmcv1.NetworkType        `json:"networkType,omitempty"`
+	PricingModel          bmcv1.ServerPricingModel `json:"pricingModel,omitempty"`
+	NetworkConfiguration  *networkConfiguration    `json:"networkConfiguration,omitempty"`
+	OSConfiguration       *osConfiguration         `json:"osConfiguration,omitempty"`
+	Tags                  []tagAssignment          `json:"tags,omitempty"`
+}
+
+type networkConfiguration struct {
+	IPBlocksConfiguration *ipBlocksConfiguration `json:"ipBlocksConfiguration,omitempty"`
+}
+
+type ipBlocksConfiguration struct {
+	ConfigurationType string         `json:"configurationType"`
+	IPBlocks          []ipBlockIDRef `json:"ipBlocks,omitempty"`
+}
+
+type ipBlockIDRef struct {
+	ID string `json:"id"`
+}
+
+type osConfiguration struct {
+	CloudInit *cloudInit `json:"cloudInit,omitempty"`
+}
+
+type cloudInit struct {
+	// UserData is base64 encoded cloud-init user data.
+	UserData string `json:"userData"`
+}
+
+// serverReprovisionRequest is the body of a BMC server reprovision action.
+type serverReprovisionRequest struct {
+	Hostname              string           `json:"hostname"`
+	Description           string           `json:"description,omitempty"`
+	OS                    bmcv1.ServerOS   `json:"os"`
+	InstallDefaultSSHKeys *bool            `json:"installDefaultSshKeys,omitempty"`
+	SSHKeyIDs             []string         `json:"sshKeyIds,omitempty"`
+	OSConfiguration       *osConfiguration `json:"osConfiguration,omitempty"`
+}
+
+// ipBlockCreateRequest is the body of a BMC IP block create call.
+type ipBlockCreateRequest struct {
+	Location      bmcv1.LocationID    `json:"location"`
+	CIDRBlockSize bmcv1.CIDRBlockSize `json:"cidrBlockSize"`
+	Description   string              `json:"description,omitempty"`
+}
+
+// tagAssignment assigns a tag, and optionally a value, to a BMC resource.
+type tagAssignment struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// tagRequest is the body of a BMC tag create or update call.
+type tagRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	IsBillingTag bool   `json:"isBillingTag"`
+}
@@ -0,0 +1,160 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// BMCBudgetReconciler reconciles a BMCBudget object
+type BMCBudgetReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcbudgets/status,verbs=get;update;patch
+
+var (
+	EventReasonBudgetExceeded = `BudgetExceeded`
+	EventReasonBudgetRestored = `BudgetRestored`
+)
+
+func (r *BMCBudgetReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("bmcbudget", req.NamespacedName)
+
+	// 1. get the BMCBudget
+	var budget bmcv1.BMCBudget
+	if err := r.Get(ctx, req.NamespacedName, &budget); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. Accumulate the cost of the servers in the namespace. The cost of
+	// servers that are deleted, or whose cost restarts because they were
+	// replaced, is retired so that the spend never decreases.
+	var servers bmcv1.ServerList
+	if err := r.List(ctx, &servers, client.InNamespace(budget.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	status := accrue(budget.Status, servers.Items)
+	status.Exceeded = parseUSD(status.Spent) > parseUSD(budget.Spec.Amount)
+
+	// 3. Report the spending
+	if status.Exceeded && !budget.Status.Exceeded {
+		log.Info(`budget exceeded`, `spent`, status.Spent, `amount`, budget.Spec.Amount)
+		r.Recorder.Eventf(&budget, `Warning`, EventReasonBudgetExceeded, "Spent %s USD of %s USD", status.Spent, budget.Spec.Amount)
+	} else if !status.Exceeded && budget.Status.Exceeded {
+		r.Recorder.Eventf(&budget, `Normal`, EventReasonBudgetRestored, "Spent %s USD of %s USD", status.Spent, budget.Spec.Amount)
+	}
+	if !reflect.DeepEqual(status, budget.Status) {
+		budget.Status = status
+		if err := r.Update(ctx, &budget); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// costs accrue over time
+	return requeueAfter5Min, nil
+}
+
+// accrue updates the budget status with the current estimated cost of the
+// servers.
+func accrue(prev bmcv1.BMCBudgetStatus, servers []bmcv1.Server) bmcv1.BMCBudgetStatus {
+	retired := parseUSD(prev.Retired)
+	costs := map[string]string{}
+	var spent, rate float64
+	for _, server := range servers {
+		uid := string(server.UID)
+		cost := parseUSD(server.Status.EstimatedCost)
+		if recorded := parseUSD(prev.Servers[uid]); cost < recorded {
+			retired += recorded
+		}
+		costs[uid] = formatUSD(cost)
+		spent += cost
+		rate += parseUSD(server.Status.HourlyRate)
+	}
+	for uid, recorded := range prev.Servers {
+		if _, ok := costs[uid]; !ok {
+			retired += parseUSD(recorded)
+		}
+	}
+	status := bmcv1.BMCBudgetStatus{
+		Spent:      formatUSD(retired + spent),
+		HourlyRate: formatUSD(rate),
+		Retired:    formatUSD(retired),
+	}
+	if len(costs) > 0 {
+		status.Servers = costs
+	}
+	return status
+}
+
+// exceededBudget returns an exceeded budget in the namespace, preferring
+// budgets that block new servers, or nil when no budget is exceeded.
+func exceededBudget(ctx context.Context, c client.Reader, namespace string) (*bmcv1.BMCBudget, error) {
+	var budgets bmcv1.BMCBudgetList
+	if err := c.List(ctx, &budgets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var exceeded *bmcv1.BMCBudget
+	for i := range budgets.Items {
+		if !budgets.Items[i].Status.Exceeded {
+			continue
+		}
+		if exceeded == nil || budgets.Items[i].Spec.Action == bmcv1.BudgetBlock {
+			exceeded = &budgets.Items[i]
+		}
+	}
+	return exceeded, nil
+}
+
+func (r *BMCBudgetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.BMCBudget{}).
+		Watches(&source.Kind{Type: &bmcv1.Server{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.serverBudgets),
+		}).
+		Complete(r)
+}
+
+// serverBudgets maps a server to the budgets of its namespace.
+func (r *BMCBudgetReconciler) serverBudgets(obj handler.MapObject) []ctrl.Request {
+	var budgets bmcv1.BMCBudgetList
+	if err := r.List(context.Background(), &budgets, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := make([]ctrl.Request, 0, len(budgets.Items))
+	for _, budget := range budgets.Items {
+		requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: budget.Namespace, Name: budget.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,108 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func costedServer(uid, cost, rate string) bmcv1.Server {
+	return bmcv1.Server{
+		ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)},
+		Status:     bmcv1.ServerStatus{EstimatedCost: cost, HourlyRate: rate},
+	}
+}
+
+func TestAccrue(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    bmcv1.BMCBudgetStatus
+		servers []bmcv1.Server
+		want    bmcv1.BMCBudgetStatus
+	}{
+		{
+			name: `no servers`,
+			want: bmcv1.BMCBudgetStatus{Spent: `0.00`, HourlyRate: `0.00`, Retired: `0.00`},
+		},
+		{
+			name:    `new servers`,
+			servers: []bmcv1.Server{costedServer(`a`, `1.50`, `0.10`), costedServer(`b`, `2.00`, `0.20`)},
+			want: bmcv1.BMCBudgetStatus{
+				Spent:      `3.50`,
+				HourlyRate: `0.30`,
+				Retired:    `0.00`,
+				Servers:    map[string]string{`a`: `1.50`, `b`: `2.00`},
+			},
+		},
+		{
+			name: `deleted server is retired`,
+			prev: bmcv1.BMCBudgetStatus{
+				Spent:   `3.50`,
+				Retired: `0.00`,
+				Servers: map[string]string{`a`: `1.50`, `b`: `2.00`},
+			},
+			servers: []bmcv1.Server{costedServer(`a`, `1.60`, `0.10`)},
+			want: bmcv1.BMCBudgetStatus{
+				Spent:      `3.60`,
+				HourlyRate: `0.10`,
+				Retired:    `2.00`,
+				Servers:    map[string]string{`a`: `1.60`},
+			},
+		},
+		{
+			name: `replaced server restarts its cost`,
+			prev: bmcv1.BMCBudgetStatus{
+				Spent:   `4.00`,
+				Retired: `1.00`,
+				Servers: map[string]string{`a`: `3.00`},
+			},
+			servers: []bmcv1.Server{costedServer(`a`, `0.10`, `0.10`)},
+			want: bmcv1.BMCBudgetStatus{
+				Spent:      `4.10`,
+				HourlyRate: `0.10`,
+				Retired:    `4.00`,
+				Servers:    map[string]string{`a`: `0.10`},
+			},
+		},
+		{
+			name: `unpriced server`,
+			prev: bmcv1.BMCBudgetStatus{
+				Spent:   `1.00`,
+				Retired: `1.00`,
+			},
+			servers: []bmcv1.Server{costedServer(`a`, ``, ``)},
+			want: bmcv1.BMCBudgetStatus{
+				Spent:      `1.00`,
+				HourlyRate: `0.00`,
+				Retired:    `1.00`,
+				Servers:    map[string]string{`a`: `0.00`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accrue(tt.prev, tt.servers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("accrue() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// BMCClusterReconciler reconciles a BMCCluster object
+type BMCClusterReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcclusters,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcclusters/status,verbs=get;update;patch
+
+func (r *BMCClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("bmccluster", req.NamespacedName)
+
+	var cluster bmcv1.BMCCluster
+	if err := r.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !cluster.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// BMC has no cluster level infrastructure to provision. The cluster is
+	// ready once a control plane endpoint has been provided, for example the
+	// first address of an IPBlock.
+	status := bmcv1.BMCClusterStatus{
+		Ready: len(cluster.Spec.ControlPlaneEndpoint.Host) > 0,
+	}
+	if len(cluster.Spec.Location) > 0 {
+		status.FailureDomains = map[string]bmcv1.FailureDomainSpec{
+			string(cluster.Spec.Location): {ControlPlane: true},
+		}
+	}
+	if !reflect.DeepEqual(status, cluster.Status) {
+		log.Info(`updating status`, `ready`, status.Ready)
+		cluster.Status = status
+		if err := r.Update(ctx, &cluster); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *BMCClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.BMCCluster{}).
+		Complete(r)
+}
@@ -0,0 +1,244 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// BMCMachineReconciler reconciles a BMCMachine object
+type BMCMachineReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcmachines,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcmachines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=bmcmachinetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;clusters,verbs=get;list;watch
+
+var (
+	bmcMachineFinalizerName = `bmcmachine.finalizers.bmc.api.phoenixnap.com`
+
+	// providerIDPrefix is the scheme of provider IDs of BMC servers.
+	providerIDPrefix = `bmc://`
+
+	capiGroup        = `cluster.x-k8s.io`
+	capiClusterLabel = `cluster.x-k8s.io/cluster-name`
+
+	EventReasonWaitingForBootstrap = `WaitingForBootstrapData`
+)
+
+func (r *BMCMachineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("bmcmachine", req.NamespacedName)
+
+	// 1. get the BMCMachine
+	var machine bmcv1.BMCMachine
+	if err := r.Get(ctx, req.NamespacedName, &machine); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. Check for deletion activity and finalizer
+	if machine.ObjectMeta.DeletionTimestamp.IsZero() {
+		found := false
+		for _, finalizer := range machine.ObjectMeta.Finalizers {
+			if finalizer == bmcMachineFinalizerName {
+				found = true
+			}
+		}
+		if !found {
+			log.Info(`attaching finalizer`)
+			machine.ObjectMeta.Finalizers = append(machine.ObjectMeta.Finalizers, bmcMachineFinalizerName)
+			if err := r.Update(ctx, &machine); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		log.Info(`finalizing`)
+
+		// Delete the server and wait for its finalizer to release the BMC
+		// server before letting Cluster API forget about the machine.
+		var server bmcv1.Server
+		err := r.Get(ctx, req.NamespacedName, &server)
+		if err == nil {
+			if server.ObjectMeta.DeletionTimestamp.IsZero() {
+				if err := r.Delete(ctx, &server); client.IgnoreNotFound(err) != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return requeueAfter1Min, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		for i, finalizer := range machine.ObjectMeta.Finalizers {
+			if finalizer == bmcMachineFinalizerName {
+				machine.ObjectMeta.Finalizers[i] = machine.ObjectMeta.Finalizers[len(machine.ObjectMeta.Finalizers)-1]
+				machine.ObjectMeta.Finalizers = machine.ObjectMeta.Finalizers[:len(machine.ObjectMeta.Finalizers)-1]
+				break
+			}
+		}
+		if err := r.Update(ctx, &machine); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 3. Find the server backing this machine, creating it once Cluster API
+	// has produced bootstrap data
+	var server bmcv1.Server
+	if err := r.Get(ctx, req.NamespacedName, &server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		dataSecretName, err := r.bootstrapDataSecretName(ctx, &machine)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(dataSecretName) == 0 {
+			log.Info(`waiting for bootstrap data`)
+			r.Recorder.Event(&machine, `Normal`, EventReasonWaitingForBootstrap, `Waiting for the owner Machine to provide bootstrap data`)
+			return requeueAfter1Min, nil
+		}
+
+		server = r.newServer(&machine, dataSecretName)
+		if err := ctrl.SetControllerReference(&machine, &server, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info(`creating server`)
+		if err := r.Create(ctx, &server); err != nil {
+			r.Recorder.Eventf(&machine, `Warning`, EventReasonCreateError, "Unable to create server: %v", err)
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(&machine, `Normal`, EventReasonCreated, "Created server %s", server.Name)
+	}
+
+	// 4. Report provider ID, addresses and readiness from the server
+	status := bmcv1.BMCMachineStatus{
+		Ready:      server.Status.BMCStatus == StatusPoweredOn,
+		ServerName: server.Name,
+		BMCStatus:  server.Status.BMCStatus,
+	}
+	status.Addresses = append(status.Addresses, bmcv1.MachineAddress{Type: bmcv1.MachineHostName, Address: server.Spec.Hostname})
+	for _, ip := range server.Status.PublicIPAddresses {
+		status.Addresses = append(status.Addresses, bmcv1.MachineAddress{Type: bmcv1.MachineExternalIP, Address: ip})
+	}
+	for _, ip := range server.Status.PrivateIPAddresses {
+		status.Addresses = append(status.Addresses, bmcv1.MachineAddress{Type: bmcv1.MachineInternalIP, Address: ip})
+	}
+	if server.Status.BMCStatus == StatusIrreconcilable {
+		reason := `CreateError`
+		message := fmt.Sprintf("server %s could not be provisioned", server.Name)
+		status.FailureReason = &reason
+		status.FailureMessage = &message
+	}
+
+	changed := false
+	if id := server.Annotations[bmcServerIDAnnotation]; len(id) > 0 && machine.Spec.ProviderID == nil {
+		providerID := providerIDPrefix + id
+		machine.Spec.ProviderID = &providerID
+		changed = true
+	}
+	if !reflect.DeepEqual(status, machine.Status) {
+		machine.Status = status
+		changed = true
+	}
+	if changed {
+		if err := r.Update(ctx, &machine); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// bootstrapDataSecretName returns spec.bootstrap.dataSecretName of the Cluster
+// API Machine owning the BMCMachine, or an empty string if it is not set yet.
+// The Machine is read as unstructured content to avoid depending on a
+// specific Cluster API release.
+func (r *BMCMachineReconciler) bootstrapDataSecretName(ctx context.Context, machine *bmcv1.BMCMachine) (string, error) {
+	for _, ref := range machine.OwnerReferences {
+		if ref.Kind != `Machine` || !strings.HasPrefix(ref.APIVersion, capiGroup+`/`) {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return ``, err
+		}
+		owner := &unstructured.Unstructured{}
+		owner.SetGroupVersionKind(gv.WithKind(`Machine`))
+		if err := r.Get(ctx, types.NamespacedName{Namespace: machine.Namespace, Name: ref.Name}, owner); err != nil {
+			return ``, client.IgnoreNotFound(err)
+		}
+		name, _, err := unstructured.NestedString(owner.Object, `spec`, `bootstrap`, `dataSecretName`)
+		return name, err
+	}
+	return ``, nil
+}
+
+// newServer builds the server backing a machine. The server is named after
+// the machine and boots with the Cluster API bootstrap data as user data.
+func (r *BMCMachineReconciler) newServer(machine *bmcv1.BMCMachine, dataSecretName string) bmcv1.Server {
+	server := bmcv1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machine.Name,
+			Namespace: machine.Namespace,
+			Labels:    map[string]string{},
+		},
+		Spec: bmcv1.ServerSpec{
+			ServerClassName:       machine.Spec.ServerClassName,
+			Hostname:              machine.Name,
+			OS:                    machine.Spec.OS,
+			Type:                  machine.Spec.Type,
+			Location:              machine.Spec.Location,
+			InstallDefaultSSHKeys: machine.Spec.InstallDefaultSSHKeys,
+			SSHKeyIDs:             machine.Spec.SSHKeyIDs,
+			NetworkType:           machine.Spec.NetworkType,
+			UserDataSecretRef:     &bmcv1.SecretKeyReference{Name: dataSecretName, Key: `value`},
+		},
+	}
+	if cluster, ok := machine.Labels[capiClusterLabel]; ok {
+		server.Labels[capiClusterLabel] = cluster
+	}
+	return server
+}
+
+func (r *BMCMachineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.BMCMachine{}).
+		Owns(&bmcv1.Server{}).
+		Complete(r)
+}
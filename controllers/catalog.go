@@ -0,0 +1,257 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	ENV_BMC_BILLING_ENDPOINT_URL = `BMC_BILLING_ENDPOINT_URL`
+
+	defaultBillingEndpointURL = `https://api.phoenixnap.com/billing/v1/`
+
+	// catalogRetryInterval limits how often a failed catalog refresh is retried.
+	catalogRetryInterval = 1 * time.Minute
+	catalogFetchTimeout  = 5 * time.Second
+)
+
+// product is a server product as returned by the BMC products API.
+type product struct {
+	ProductCode string `json:"productCode"`
+	Plans       []struct {
+		Location     bmcv1.LocationID         `json:"location"`
+		PricingModel bmcv1.ServerPricingModel `json:"pricingModel"`
+		Price        float64                  `json:"price"`
+		PriceUnit    string                   `json:"priceUnit"`
+	} `json:"plans"`
+}
+
+// productAvailability is a server product as returned by the BMC product
+// availability API.
+type productAvailability struct {
+	ProductCode                 string `json:"productCode"`
+	LocationAvailabilityDetails []struct {
+		Location          bmcv1.LocationID `json:"location"`
+		AvailableQuantity int              `json:"availableQuantity"`
+	} `json:"locationAvailabilityDetails"`
+}
+
+// BMCCatalog is a ServerCatalog backed by the BMC product availability API.
+// Products are cached for the refresh interval. Until the API has been read
+// successfully the static catalog is used instead.
+type BMCCatalog struct {
+	Log             logr.Logger
+	RefreshInterval time.Duration
+
+	// mu guards the cached products and prices. It is not held while the
+	// BMC API is called; concurrent callers use the cached values instead.
+	mu        sync.Mutex
+	nextFetch time.Time
+	fetching  bool
+	available map[bmcv1.ServerType]map[bmcv1.LocationID]int
+
+	nextPriceFetch time.Time
+	fetchingPrices bool
+	prices         PriceTable
+}
+
+var _ bmcv1.ServerCatalog = &BMCCatalog{}
+
+// NewBMCCatalog returns a catalog that reads the BMC products API at most once
+// per refresh interval.
+func NewBMCCatalog(refreshInterval time.Duration) *BMCCatalog {
+	return &BMCCatalog{
+		Log:             ctrl.Log.WithName("catalog"),
+		RefreshInterval: refreshInterval,
+	}
+}
+
+// Offered implements bmcv1.ServerCatalog.
+func (c *BMCCatalog) Offered(t bmcv1.ServerType, l bmcv1.LocationID) (bool, bool) {
+	available := c.products()
+	if available == nil {
+		return bmcv1.StaticCatalog.Offered(t, l)
+	}
+	locations, ok := available[t]
+	if !ok {
+		return false, false
+	}
+	_, offered := locations[l]
+	return offered, true
+}
+
+// KnownLocation implements bmcv1.ServerCatalog.
+func (c *BMCCatalog) KnownLocation(l bmcv1.LocationID) bool {
+	available := c.products()
+	if available == nil {
+		return bmcv1.StaticCatalog.KnownLocation(l)
+	}
+	for _, locations := range available {
+		if _, ok := locations[l]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Available returns the number of servers of a type currently in stock in a
+// location and whether the catalog has stock information for them.
+func (c *BMCCatalog) Available(t bmcv1.ServerType, l bmcv1.LocationID) (int, bool) {
+	available := c.products()
+	if available == nil {
+		return 0, false
+	}
+	quantity, ok := available[t][l]
+	return quantity, ok
+}
+
+// HourlyRate returns the hourly rate of a server product in USD as listed by
+// the BMC products API.
+func (c *BMCCatalog) HourlyRate(t bmcv1.ServerType, l bmcv1.LocationID, pm bmcv1.ServerPricingModel) (float64, bool) {
+	c.mu.Lock()
+	if c.fetchingPrices || time.Now().Before(c.nextPriceFetch) {
+		defer c.mu.Unlock()
+		return c.prices.HourlyRate(t, l, pm)
+	}
+	c.fetchingPrices = true
+	c.mu.Unlock()
+
+	prices, err := fetchPrices()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetchingPrices = false
+	if err != nil {
+		c.Log.Info(`unable to read product prices`, `error`, err.Error())
+		c.nextPriceFetch = time.Now().Add(catalogRetryInterval)
+	} else {
+		c.prices = prices
+		c.nextPriceFetch = time.Now().Add(c.RefreshInterval)
+	}
+	return c.prices.HourlyRate(t, l, pm)
+}
+
+// products returns the cached products, refreshing them when they are stale.
+// It returns nil when the API has never been read successfully.
+func (c *BMCCatalog) products() map[bmcv1.ServerType]map[bmcv1.LocationID]int {
+	c.mu.Lock()
+	if c.fetching || time.Now().Before(c.nextFetch) {
+		defer c.mu.Unlock()
+		return c.available
+	}
+	c.fetching = true
+	c.mu.Unlock()
+
+	available, err := fetchProducts()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetching = false
+	if err != nil {
+		c.Log.Info(`unable to read product catalog, using cached or static catalog`, `error`, err.Error())
+		c.nextFetch = time.Now().Add(catalogRetryInterval)
+		return c.available
+	}
+	c.available = available
+	c.nextFetch = time.Now().Add(c.RefreshInterval)
+	return c.available
+}
+
+// fetchProducts reads the server products and their stock per location.
+func fetchProducts() (map[bmcv1.ServerType]map[bmcv1.LocationID]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), catalogFetchTimeout)
+	defer cancel()
+	bmc := bmcClient(ctx)
+
+	apiResp, err := bmc.Get(fmt.Sprintf("%sproduct-availability?productCategory=SERVER", endpointURL(ENV_BMC_BILLING_ENDPOINT_URL, defaultBillingEndpointURL)))
+	if err != nil {
+		return nil, err
+	}
+	defer apiResp.Body.Close()
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if apiResp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected response from product availability API: %v", apiResp.StatusCode)
+	}
+
+	var products []productAvailability
+	if err := json.Unmarshal(body, &products); err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, fmt.Errorf(`product availability API returned no server products`)
+	}
+	available := map[bmcv1.ServerType]map[bmcv1.LocationID]int{}
+	for _, p := range products {
+		locations := map[bmcv1.LocationID]int{}
+		for _, d := range p.LocationAvailabilityDetails {
+			locations[d.Location] = d.AvailableQuantity
+		}
+		available[bmcv1.ServerType(p.ProductCode)] = locations
+	}
+	return available, nil
+}
+
+// fetchPrices reads the price plans of the server products and converts them
+// to hourly rates.
+func fetchPrices() (PriceTable, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), catalogFetchTimeout)
+	defer cancel()
+	bmc := bmcClient(ctx)
+
+	apiResp, err := bmc.Get(fmt.Sprintf("%sproducts?productCategory=SERVER", endpointURL(ENV_BMC_BILLING_ENDPOINT_URL, defaultBillingEndpointURL)))
+	if err != nil {
+		return nil, err
+	}
+	defer apiResp.Body.Close()
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if apiResp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected response from products API: %v", apiResp.StatusCode)
+	}
+
+	var products []product
+	if err := json.Unmarshal(body, &products); err != nil {
+		return nil, err
+	}
+	prices := PriceTable{}
+	for _, p := range products {
+		for _, plan := range p.Plans {
+			rate := plan.Price
+			if plan.PriceUnit == `MONTH` {
+				rate = plan.Price / hoursPerMonth
+			}
+			prices[priceKey(bmcv1.ServerType(p.ProductCode), plan.Location, plan.PricingModel)] = rate
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,317 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// IPBlockReconciler reconciles an IPBlock object
+type IPBlockReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=ipblocks,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=ipblocks/status,verbs=get;update;patch
+
+var (
+	bmcIPBlockIDAnnotation = `bmc.api.phoenixnap.com/ip_block_id`
+
+	ipBlockFinalizerName = `ipblock.finalizers.bmc.api.phoenixnap.com`
+
+	IPBlockStatusAssigned   = `assigned`
+	IPBlockStatusUnassigned = `unassigned`
+)
+
+func (r *IPBlockReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("ipblock", req.NamespacedName)
+
+	// 1. get the IPBlock
+	var ipBlock bmcv1.IPBlock
+	if err := r.Get(ctx, req.NamespacedName, &ipBlock); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. Load a BMC client
+	bmc := bmcClient(ctx)
+	endpoint := endpointURL(ENV_BMC_IPS_ENDPOINT_URL, defaultIPsEndpointURL)
+
+	// 3. Check for deletion activity and finalizer
+	if ipBlock.ObjectMeta.DeletionTimestamp.IsZero() {
+		found := false
+		for _, finalizer := range ipBlock.ObjectMeta.Finalizers {
+			if finalizer == ipBlockFinalizerName {
+				found = true
+			}
+		}
+		if !found {
+			log.Info(`attaching finalizer`)
+			ipBlock.ObjectMeta.Finalizers = append(ipBlock.ObjectMeta.Finalizers, ipBlockFinalizerName)
+			if err := r.Update(ctx, &ipBlock); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		log.Info(`finalizing`)
+
+		bmcIPBlockID := ipBlock.Annotations[bmcIPBlockIDAnnotation]
+		if ipBlock.Status.BMCStatus != StatusOrphaned && ipBlock.Status.BMCStatus != StatusGone && len(bmcIPBlockID) > 0 {
+			apiReq, err := http.NewRequest(
+				http.MethodDelete,
+				fmt.Sprintf("%sip-blocks/%s", endpoint, bmcIPBlockID),
+				nil)
+			if err != nil {
+				r.Recorder.Event(&ipBlock, `Warning`, EventReasonCleanupError, err.Error())
+				return ctrl.Result{}, err
+			}
+			apiResp, err := bmc.Do(apiReq)
+			if err != nil {
+				r.Recorder.Event(&ipBlock, `Warning`, EventReasonCleanupError, err.Error())
+				return ctrl.Result{}, err
+			}
+			defer apiResp.Body.Close()
+
+			body, err := ioutil.ReadAll(apiResp.Body)
+			if err != nil {
+				r.Recorder.Event(&ipBlock, `Warning`, EventReasonCleanupError, err.Error())
+				return ctrl.Result{}, err
+			}
+
+			switch apiResp.StatusCode {
+			case 400, 409:
+				// the block is still assigned to a server, wait for it to be released
+				r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonCleanupError, `Code: %v`, apiResp.StatusCode)
+				log.Info("unable to delete", `code`, apiResp.StatusCode, `body`, string(body))
+				return requeueAfter2Min, nil
+			case 401:
+				// bad credentials
+				log.Info("unable to delete", `code`, 401, `body`, string(body))
+				ipBlock.Status.BMCStatus = StatusIrreconcilable
+				if err := r.Update(ctx, &ipBlock); err != nil {
+					return ctrl.Result{}, err
+				}
+				return requeueAfter2Min, nil
+			case 403:
+				// unauthorized
+				log.Info("unable to delete", `code`, 403, `body`, string(body))
+				ipBlock.Status.BMCStatus = StatusOrphaned
+				if err := r.Update(ctx, &ipBlock); err != nil {
+					return ctrl.Result{}, err
+				}
+				return requeueAfter2Min, nil
+			case 500:
+				// temporarily unavailable, backoff and retry
+				log.Info(`BMC temporarily unavailable`, `body`, string(body))
+				return requeueAfter2Min, nil
+			case 200, 202, 204:
+				r.Recorder.Eventf(&ipBlock, `Normal`, EventReasonCleanupSuccess, "Deleted BMC IP block %s", bmcIPBlockID)
+			case 404:
+				// already deleted out of band
+				r.Recorder.Eventf(&ipBlock, `Normal`, EventReasonCleanupSuccess, "BMC IP block %s was already deleted", bmcIPBlockID)
+			default:
+				r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonCleanupError, "Unexpected response from API: %v", apiResp.StatusCode)
+				return requeueAfter2Min, fmt.Errorf("unexpected response during IP block delete: %v", apiResp.StatusCode)
+			}
+		}
+
+		for i, finalizer := range ipBlock.ObjectMeta.Finalizers {
+			if finalizer == ipBlockFinalizerName {
+				ipBlock.ObjectMeta.Finalizers[i] = ipBlock.ObjectMeta.Finalizers[len(ipBlock.ObjectMeta.Finalizers)-1]
+				ipBlock.ObjectMeta.Finalizers = ipBlock.ObjectMeta.Finalizers[:len(ipBlock.ObjectMeta.Finalizers)-1]
+				break
+			}
+		}
+		if err := r.Update(ctx, &ipBlock); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 4. Create or poll? Branch on the bmcIPBlockID annotation
+	bmcIPBlockID := ipBlock.Annotations[bmcIPBlockIDAnnotation]
+	if len(bmcIPBlockID) == 0 {
+		log.Info(`creating`)
+		createBody, err := json.Marshal(ipBlockCreateRequest{
+			Location:      ipBlock.Spec.Location,
+			CIDRBlockSize: ipBlock.Spec.CIDRBlockSize,
+			Description:   ipBlock.Spec.Description,
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		apiResp, err := bmc.Post(fmt.Sprintf("%sip-blocks", endpoint), `application/json`, bytes.NewBuffer(createBody))
+		if err != nil {
+			r.Recorder.Event(&ipBlock, `Warning`, EventReasonCreateError, err.Error())
+			return ctrl.Result{}, err
+		}
+		defer apiResp.Body.Close()
+
+		body, err := ioutil.ReadAll(apiResp.Body)
+		if err != nil {
+			r.Recorder.Event(&ipBlock, `Warning`, EventReasonCreateError, err.Error())
+			return ctrl.Result{}, err
+		}
+
+		switch apiResp.StatusCode {
+		case 400, 401, 403, 409:
+			// bad data, bad credentials, or controller/API incompatibility
+			r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonCreateErrorPermanent, `Code: %v`, apiResp.StatusCode)
+			log.Info("unable to reconcile", `code`, apiResp.StatusCode, `body`, string(body))
+			ipBlock.Status.BMCStatus = StatusIrreconcilable
+			if err := r.Update(ctx, &ipBlock); err != nil {
+				return ctrl.Result{}, err
+			}
+			// something is wrong with the controller or input, stop polling
+			return ctrl.Result{}, nil
+		case 406:
+			// no public IP inventory in the location, backoff and retry
+			r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonCreateErrorInventory, `Code: %v`, apiResp.StatusCode)
+			log.Info("temporary no inventory", `code`, 406, `body`, string(body))
+			return requeueAfter5Min, nil
+		case 500:
+			// temporarily unavailable, backoff and retry
+			r.Recorder.Event(&ipBlock, `Warning`, EventReasonCreateFailure, `Temporary API failure`)
+			log.Info(`BMC temporarily unavailable`, `body`, string(body))
+			return requeueAfter2Min, nil
+		case 200, 201:
+			// the call was successful, do nothing and continue reconciliation
+		default:
+			r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonCreateError, `Unexpected response from API: %v`, apiResp.StatusCode)
+			return ctrl.Result{}, fmt.Errorf("unexpected response during IP block create: %v", apiResp.StatusCode)
+		}
+
+		var ss bmcv1.IPBlockStatus
+		if err := json.Unmarshal(body, &ss); err != nil {
+			r.Recorder.Event(&ipBlock, `Warning`, EventReasonCreateError, err.Error())
+			return ctrl.Result{}, err
+		}
+
+		r.Recorder.Eventf(&ipBlock, `Normal`, EventReasonCreated, "created BMC IP block %s", ss.BMCIPBlockID)
+
+		ipBlock.Status = ss
+		if ipBlock.Annotations == nil {
+			ipBlock.Annotations = map[string]string{}
+		}
+		ipBlock.Annotations[bmcIPBlockIDAnnotation] = ss.BMCIPBlockID
+		if err := r.Update(ctx, &ipBlock); err != nil {
+			return ctrl.Result{}, err
+		}
+		return requeueAfter1Min, nil
+	}
+
+	if ipBlock.Status.BMCStatus == StatusGone {
+		// nothing left to poll
+		return ctrl.Result{}, nil
+	}
+
+	log.Info(`polling`)
+	apiResp, err := bmc.Get(fmt.Sprintf("%sip-blocks/%s", endpoint, bmcIPBlockID))
+	if err != nil {
+		return requeueAfter2Min, err
+	}
+	defer apiResp.Body.Close()
+
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		return requeueAfter2Min, err
+	}
+
+	switch apiResp.StatusCode {
+	case 400, 401:
+		r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonPollFailure, `Code: %v`, apiResp.StatusCode)
+		log.Info("unable to reconcile", `code`, apiResp.StatusCode, `body`, string(body))
+		ipBlock.Status.BMCStatus = StatusIrreconcilable
+		if err := r.Update(ctx, &ipBlock); err != nil {
+			return ctrl.Result{}, err
+		}
+		return requeueAfter5Min, nil
+	case 404:
+		// deleted out of band
+		r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonIPBlockGone, "BMC IP block %s no longer exists", bmcIPBlockID)
+		log.Info(`BMC IP block no longer exists`, `code`, 404, `body`, string(body))
+		ipBlock.Status.BMCStatus = StatusGone
+		if err := r.Update(ctx, &ipBlock); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	case 403:
+		// unauthorized
+		r.Recorder.Event(&ipBlock, `Warning`, EventReasonResourceOrphaned, `Access to BMC resource was denied`)
+		log.Info("unable to reconcile", `code`, 403, `body`, string(body))
+		ipBlock.Status.BMCStatus = StatusOrphaned
+		if err := r.Update(ctx, &ipBlock); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	case 500:
+		r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonPollFailure, `Code: %v`, apiResp.StatusCode)
+		log.Info(`BMC temporarily unavailable`, `body`, string(body))
+		ipBlock.Status.BMCStatus = StatusStale
+		if err := r.Update(ctx, &ipBlock); err != nil {
+			return ctrl.Result{}, err
+		}
+		return requeueAfter5Min, nil
+	case 200:
+	default:
+		r.Recorder.Eventf(&ipBlock, `Warning`, EventReasonPollFailure, `Unexpected response from API: %v`, apiResp.StatusCode)
+		return ctrl.Result{}, fmt.Errorf("unexpected response during IP block poll: %v", apiResp.StatusCode)
+	}
+
+	var ss bmcv1.IPBlockStatus
+	if err := json.Unmarshal(body, &ss); err != nil {
+		return requeueAfter2Min, err
+	}
+	if ipBlock.Status.BMCStatus != ss.BMCStatus {
+		r.Recorder.Eventf(&ipBlock, `Normal`, EventReasonStatusChange, `%v -> %v`, ipBlock.Status.BMCStatus, ss.BMCStatus)
+	}
+	ipBlock.Status = ss
+	if err := r.Update(ctx, &ipBlock); err != nil {
+		return requeueAfter2Min, err
+	}
+
+	// IP blocks settle quickly and only change when attached or released
+	switch ss.BMCStatus {
+	case IPBlockStatusAssigned, IPBlockStatusUnassigned:
+		return requeueAfter5Min, nil
+	default:
+		return requeueAfter1Min, nil
+	}
+}
+
+func (r *IPBlockReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.IPBlock{}).
+		Complete(r)
+}
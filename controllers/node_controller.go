@@ -0,0 +1,198 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// NodeReconciler links Nodes to the Servers they run on
+type NodeReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+
+var (
+	LabelTopologyRegion = `topology.kubernetes.io/region`
+	LabelInstanceType   = `node.kubernetes.io/instance-type`
+	LabelServerName     = `bmc.api.phoenixnap.com/server`
+
+	EventReasonNodeLinked = `NodeLinked`
+)
+
+func (r *NodeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("node", req.NamespacedName)
+
+	var servers bmcv1.ServerList
+	if err := r.List(ctx, &servers); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 1. get the Node, unlinking servers from Nodes that are gone
+	var node corev1.Node
+	if err := r.Get(ctx, req.NamespacedName, &node); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		for i := range servers.Items {
+			server := &servers.Items[i]
+			if server.Status.NodeRef != nil && server.Status.NodeRef.Name == req.Name {
+				log.Info(`unlinking`, `server`, server.Name)
+				server.Status.NodeRef = nil
+				if err := r.Update(ctx, server); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 2. Find the server the Node runs on
+	server := matchServer(&node, servers.Items)
+	if server == nil {
+		return ctrl.Result{}, nil
+	}
+
+	// 3. Label the Node with server topology
+	desired := map[string]string{
+		LabelTopologyRegion: string(server.Spec.Location),
+		LabelInstanceType:   string(server.Spec.Type),
+		LabelServerName:     server.Name,
+	}
+	// a preferred fallback may have been used when the server was created
+	if len(server.Status.Location) > 0 {
+		desired[LabelTopologyRegion] = string(server.Status.Location)
+	}
+	if len(server.Status.Type) > 0 {
+		desired[LabelInstanceType] = string(server.Status.Type)
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	changed := false
+	for k, v := range desired {
+		if len(v) > 0 && node.Labels[k] != v {
+			if node.Labels == nil {
+				node.Labels = map[string]string{}
+			}
+			node.Labels[k] = v
+			changed = true
+		}
+	}
+	if changed {
+		log.Info(`labeling`, `server`, server.Name)
+		if err := r.Patch(ctx, &node, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// 4. Record the Node on the server
+	if ref := server.Status.NodeRef; ref == nil || ref.Name != node.Name || ref.UID != node.UID {
+		log.Info(`linking`, `server`, server.Name)
+		server.Status.NodeRef = &corev1.ObjectReference{
+			APIVersion: `v1`,
+			Kind:       `Node`,
+			Name:       node.Name,
+			UID:        node.UID,
+		}
+		if err := r.Update(ctx, server); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(server, `Normal`, EventReasonNodeLinked, "Linked to node %s", node.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// matchServer finds the server a Node runs on. The provider ID is
+// authoritative; otherwise the hostname and then the Node addresses are
+// compared with the server.
+func matchServer(node *corev1.Node, servers []bmcv1.Server) *bmcv1.Server {
+	if strings.HasPrefix(node.Spec.ProviderID, providerIDPrefix) {
+		id := strings.TrimPrefix(node.Spec.ProviderID, providerIDPrefix)
+		for i := range servers {
+			if servers[i].Annotations[bmcServerIDAnnotation] == id {
+				return &servers[i]
+			}
+		}
+		return nil
+	}
+	for i := range servers {
+		if servers[i].Spec.Hostname == node.Name {
+			return &servers[i]
+		}
+	}
+	for i := range servers {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if hasIP(servers[i].Status.PublicIPAddresses, addr.Address) || hasIP(servers[i].Status.PrivateIPAddresses, addr.Address) {
+				return &servers[i]
+			}
+		}
+	}
+	return nil
+}
+
+func hasIP(ips []string, ip string) bool {
+	for _, v := range ips {
+		if v == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// serverNode maps a server to the Node it is, or is likely to be, linked to.
+func serverNode(o handler.MapObject) []reconcile.Request {
+	server, ok := o.Object.(*bmcv1.Server)
+	if !ok {
+		return nil
+	}
+	name := server.Spec.Hostname
+	if server.Status.NodeRef != nil {
+		name = server.Status.NodeRef.Name
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
+
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}).
+		Watches(&source.Kind{Type: &bmcv1.Server{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(serverNode),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,229 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// NodeLifecycleReconciler performs the node lifecycle duties of a cloud
+// controller manager for Nodes running on BMC servers. It initializes Nodes
+// with their provider ID, addresses and topology labels from the BMC server
+// record, and deletes Nodes whose BMC server no longer exists.
+type NodeLifecycleReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=get;update;patch
+
+var (
+	// TaintExternalCloudProvider is set by kubelets started with --cloud-provider=external
+	// until the Node has been initialized.
+	TaintExternalCloudProvider = `node.cloudprovider.kubernetes.io/uninitialized`
+
+	nodeInitializedAnnotation = `bmc.api.phoenixnap.com/node-initialized`
+
+	EventReasonNodeInitialized = `NodeInitialized`
+	EventReasonNodeDeleted     = `DeletingNode`
+	EventReasonNodeLookupError = `NodeLookupError`
+)
+
+func (r *NodeLifecycleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("node", req.NamespacedName)
+
+	// 1. get the Node
+	var node corev1.Node
+	if err := r.Get(ctx, req.NamespacedName, &node); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if len(node.Spec.ProviderID) > 0 && !strings.HasPrefix(node.Spec.ProviderID, providerIDPrefix) {
+		// managed by another cloud provider
+		return ctrl.Result{}, nil
+	}
+
+	// 2. Load the BMC server record, by provider ID or by hostname
+	bmc := bmcClient(ctx)
+	var record *serverRecord
+	if id := strings.TrimPrefix(node.Spec.ProviderID, providerIDPrefix); len(id) > 0 {
+		apiResp, err := bmc.Get(fmt.Sprintf("%sservers/%s", os.Getenv(ENV_BMC_ENDPOINT_URL), id))
+		if err != nil {
+			return requeueAfter2Min, err
+		}
+		defer apiResp.Body.Close()
+
+		body, err := ioutil.ReadAll(apiResp.Body)
+		if err != nil {
+			return requeueAfter2Min, err
+		}
+
+		switch apiResp.StatusCode {
+		case 403:
+			// credentials or permissions problem, the server may still exist
+			log.Info(`unable to load server`, `code`, 403, `body`, string(body))
+			r.Recorder.Eventf(&node, `Warning`, EventReasonNodeLookupError, "Not permitted to read BMC server %s", id)
+			return requeueAfter2Min, nil
+		case 404:
+			// the BMC server is gone, remove the Node like a cloud provider would
+			log.Info(`deleting node`, `code`, 404, `server`, id)
+			r.Recorder.Eventf(&node, `Normal`, EventReasonNodeDeleted, "BMC server %s no longer exists", id)
+			if err := r.Delete(ctx, &node); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		case 200:
+		default:
+			log.Info(`unable to load server`, `code`, apiResp.StatusCode, `body`, string(body))
+			return requeueAfter2Min, nil
+		}
+		record = &serverRecord{}
+		if err := json.Unmarshal(body, record); err != nil {
+			return requeueAfter2Min, err
+		}
+	} else {
+		records, err := listServers(bmc)
+		if err != nil {
+			return requeueAfter2Min, err
+		}
+		for i := range records {
+			if records[i].Hostname == node.Name {
+				record = &records[i]
+				break
+			}
+		}
+		if record == nil {
+			// not a BMC server
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// 3. Initialize the Node spec and labels
+	patch := client.MergeFrom(node.DeepCopy())
+	if len(node.Spec.ProviderID) == 0 {
+		node.Spec.ProviderID = providerIDPrefix + record.BMCServerID
+	}
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[LabelTopologyRegion] = string(record.Location)
+	node.Labels[LabelInstanceType] = string(record.Type)
+	var taints []corev1.Taint
+	for _, taint := range node.Spec.Taints {
+		if taint.Key != TaintExternalCloudProvider {
+			taints = append(taints, taint)
+		}
+	}
+	initialized := len(taints) != len(node.Spec.Taints)
+	node.Spec.Taints = taints
+	if initialized {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[nodeInitializedAnnotation] = `true`
+	}
+	if err := r.Patch(ctx, &node, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	if initialized {
+		log.Info(`initialized`, `server`, record.BMCServerID)
+		r.Recorder.Eventf(&node, `Normal`, EventReasonNodeInitialized, "Initialized from BMC server %s", record.BMCServerID)
+	}
+
+	// 4. Publish the server addresses. Kubelets that are not running with an
+	// external cloud provider report their own addresses.
+	if node.Annotations[nodeInitializedAnnotation] != `true` {
+		return requeueAfter5Min, nil
+	}
+	addresses := []corev1.NodeAddress{{Type: corev1.NodeHostName, Address: node.Name}}
+	for _, ip := range record.PrivateIPAddresses {
+		addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip})
+	}
+	for _, ip := range record.PublicIPAddresses {
+		addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: ip})
+	}
+	if !reflect.DeepEqual(addresses, node.Status.Addresses) {
+		node.Status.Addresses = addresses
+		if err := r.Status().Update(ctx, &node); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// check back for deleted servers
+	return requeueAfter5Min, nil
+}
+
+// listServers returns all servers of the BMC account.
+func listServers(bmc *http.Client) ([]serverRecord, error) {
+	apiResp, err := bmc.Get(fmt.Sprintf("%sservers", os.Getenv(ENV_BMC_ENDPOINT_URL)))
+	if err != nil {
+		return nil, err
+	}
+	defer apiResp.Body.Close()
+
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if apiResp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected response during server list: %v", apiResp.StatusCode)
+	}
+	var records []serverRecord
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (r *NodeLifecycleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(`nodelifecycle`).
+		For(&corev1.Node{}).
+		WithEventFilter(predicate.Funcs{
+			// Nodes update their status frequently; only spec and label
+			// changes need an immediate look, the rest is periodic.
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldNode, ok := e.ObjectOld.(*corev1.Node)
+				newNode, ok2 := e.ObjectNew.(*corev1.Node)
+				if !ok || !ok2 {
+					return true
+				}
+				return !reflect.DeepEqual(oldNode.Spec, newNode.Spec) ||
+					!reflect.DeepEqual(oldNode.Labels, newNode.Labels)
+			},
+		}).
+		Complete(r)
+}
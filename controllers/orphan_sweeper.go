@@ -0,0 +1,232 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	orphanedServers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: `bmc_orphaned_servers`,
+		Help: `Number of BMC servers tagged by this controller without a Server resource`,
+	})
+	orphanedServersDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: `bmc_orphaned_servers_deleted_total`,
+		Help: `Number of orphaned BMC servers deleted by the sweeper`,
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedServers, orphanedServersDeleted)
+}
+
+var (
+	EventReasonOrphanDetected     = `OrphanDetected`
+	EventReasonOrphanDeleted      = `OrphanDeleted`
+	EventReasonOrphanCleanupError = `OrphanCleanupError`
+)
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// OrphanSweeper periodically looks for BMC servers carrying the cluster tag
+// of this cluster whose Server resource no longer exists, for example because
+// its finalizer was removed by hand. Orphans are reported once they have been
+// seen for the grace period and deleted when Delete is set. Events are
+// recorded on the namespace of the missing Server resource. BMC servers of
+// other clusters sharing the account are never considered.
+type OrphanSweeper struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+
+	// ClusterID selects the BMC servers created in this cluster.
+	ClusterID string
+
+	Interval    time.Duration
+	GracePeriod time.Duration
+	Delete      bool
+
+	// firstSeen records when each orphaned BMC server was first noticed.
+	firstSeen map[string]time.Time
+	// reported records the orphaned BMC servers already reported.
+	reported map[string]bool
+}
+
+var _ manager.Runnable = &OrphanSweeper{}
+
+// Start implements manager.Runnable. The sweeper runs on the leader only.
+// The first sweep runs immediately.
+func (s *OrphanSweeper) Start(stop <-chan struct{}) error {
+	s.firstSeen = map[string]time.Time{}
+	s.reported = map[string]bool{}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.sweep(context.Background()); err != nil {
+			s.Log.Info(`orphan sweep failed`, `error`, err.Error())
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep compares the tagged BMC servers with the Server resources in all
+// namespaces.
+func (s *OrphanSweeper) sweep(ctx context.Context) error {
+	if len(s.ClusterID) == 0 {
+		return fmt.Errorf(`cluster ID is not configured`)
+	}
+	var servers bmcv1.ServerList
+	if err := s.List(ctx, &servers); err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, server := range servers.Items {
+		known[createKey(&server)] = true
+		if id := server.Annotations[bmcServerIDAnnotation]; len(id) > 0 {
+			known[id] = true
+		}
+	}
+
+	bmc := bmcClient(ctx)
+	records, err := listServers(bmc)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	seen := map[string]time.Time{}
+	reported := map[string]bool{}
+	count := 0
+	for _, record := range records {
+		if cluster, ok := tagValue(record.Tags, clusterTagName); !ok || cluster != s.ClusterID {
+			continue
+		}
+		owner, tagged := tagValue(record.Tags, ownerTagName)
+		if !tagged || known[owner] || known[record.BMCServerID] {
+			continue
+		}
+		first, ok := s.firstSeen[record.BMCServerID]
+		if !ok {
+			first = now
+		}
+		seen[record.BMCServerID] = first
+		if now.Sub(first) < s.GracePeriod {
+			continue
+		}
+		count++
+
+		ns := s.namespace(ctx, record.Tags)
+		if !s.Delete {
+			if !s.reported[record.BMCServerID] {
+				s.Log.Info(`orphaned BMC server`, `id`, record.BMCServerID, `hostname`, record.Hostname, `owner`, owner)
+				s.event(ns, `Warning`, EventReasonOrphanDetected, "BMC server %s (%s) has no Server resource", record.BMCServerID, record.Hostname)
+			}
+			reported[record.BMCServerID] = true
+			continue
+		}
+		if err := deleteBMCServer(bmc, record.BMCServerID, false); err != nil {
+			s.Log.Info(`unable to delete orphaned BMC server`, `id`, record.BMCServerID, `hostname`, record.Hostname, `error`, err.Error())
+			s.event(ns, `Warning`, EventReasonOrphanCleanupError, "Unable to delete orphaned BMC server %s (%s): %v", record.BMCServerID, record.Hostname, err)
+			continue
+		}
+		s.Log.Info(`deleted orphaned BMC server`, `id`, record.BMCServerID, `hostname`, record.Hostname, `owner`, owner)
+		s.event(ns, `Normal`, EventReasonOrphanDeleted, "Deleted orphaned BMC server %s (%s)", record.BMCServerID, record.Hostname)
+		orphanedServersDeleted.Inc()
+		delete(seen, record.BMCServerID)
+		count--
+	}
+	s.firstSeen = seen
+	s.reported = reported
+	orphanedServers.Set(float64(count))
+	return nil
+}
+
+// namespace returns the namespace named by the namespace tag of an orphaned
+// BMC server, or nil when the server predates the tag or the namespace no
+// longer exists.
+func (s *OrphanSweeper) namespace(ctx context.Context, tags []bmcv1.ServerTag) *corev1.Namespace {
+	name, ok := tagValue(tags, namespaceTagName)
+	if !ok || len(name) == 0 {
+		return nil
+	}
+	var ns corev1.Namespace
+	if err := s.Get(ctx, types.NamespacedName{Name: name}, &ns); err != nil {
+		return nil
+	}
+	return &ns
+}
+
+// event records an event on the namespace of an orphaned BMC server. Without
+// a namespace the orphan is only logged.
+func (s *OrphanSweeper) event(ns *corev1.Namespace, eventtype, reason, messageFmt string, args ...interface{}) {
+	if ns == nil || s.Recorder == nil {
+		return
+	}
+	s.Recorder.Eventf(ns, eventtype, reason, messageFmt, args...)
+}
+
+// deleteBMCServer deletes a BMC server. With keepIPBlocks the server is
+// deprovisioned instead so that its IP blocks are not released. Servers that
+// are already gone are not an error.
+func deleteBMCServer(bmc *http.Client, id string, keepIPBlocks bool) error {
+	method := http.MethodDelete
+	url := fmt.Sprintf("%sservers/%s", os.Getenv(ENV_BMC_ENDPOINT_URL), id)
+	var reqBody io.Reader
+	if keepIPBlocks {
+		method = http.MethodPost
+		url = fmt.Sprintf("%sservers/%s/actions/deprovision", os.Getenv(ENV_BMC_ENDPOINT_URL), id)
+		reqBody = bytes.NewBufferString(`{"deleteIpBlocks":false}`)
+	}
+	apiReq, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	apiReq.Header.Set(`Content-Type`, `application/json`)
+	apiResp, err := bmc.Do(apiReq)
+	if err != nil {
+		return err
+	}
+	defer apiResp.Body.Close()
+	switch apiResp.StatusCode {
+	case 200, 202, 204, 404:
+		return nil
+	default:
+		return fmt.Errorf("unexpected response during server delete: %v", apiResp.StatusCode)
+	}
+}
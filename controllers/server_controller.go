@@ -19,16 +19,23 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
-	"golang.org/x/oauth2/clientcredentials"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -42,10 +49,38 @@ type ServerReconciler struct {
 	Recorder record.EventRecorder
 	Log      logr.Logger
 	Scheme   *runtime.Scheme
+
+	// KubeClient is used for operations the controller-runtime client does
+	// not support, such as pod eviction when draining nodes.
+	KubeClient kubernetes.Interface
+
+	// ExpiryWarnings are the lead times before a server expires at which a
+	// warning event is emitted. DefaultExpiryWarnings is used when nil.
+	ExpiryWarnings []time.Duration
+
+	// Prices overrides the hourly rates listed by the BMC products API.
+	Prices PriceTable
+
+	// Catalog reports the server products offered by BMC. Product checks are
+	// skipped when it is nil.
+	Catalog *BMCCatalog
+
+	// ClusterID is written to the cluster tag of every BMC server.
+	ClusterID string
+
+	// pendingCredentials holds one-time credentials by server UID that could
+	// not be stored in a Secret until they are written to the connection
+	// secret.
+	credentialsMu      sync.Mutex
+	pendingCredentials map[types.UID]*serverCredentials
 }
 
 // +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=servers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=servers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=ipblocks,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services;endpoints,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch;delete
 
 var (
 	bmcServerIDAnnotation = `bmc.api.phoenixnap.com/server_id`
@@ -70,17 +105,25 @@ var (
 	EventReasonCreateErrorPermanent = `CreateErrorPermanent`
 	EventReasonCreateErrorInventory = `CreateErrorInventory`
 	EventReasonCreateFailure        = `CreateServerFailure`
+	EventReasonProductNotOffered    = `ProductNotOffered`
+	EventReasonProductUnavailable   = `ProductUnavailable`
+
+	EventReasonIPBlockPending  = `IPBlockPending`
+	EventReasonUserDataPending = `UserDataPending`
 
 	EventReasonResourceOrphaned = `ResourceOrphaned`
+	EventReasonServerGone       = `ServerGone`
+	EventReasonIPBlockGone      = `IPBlockGone`
 	EventReasonPollFailure      = `PollingFailure`
 	EventReasonStatusChange     = `StatusChange`
 
 	StatusIrreconcilable = `irreconcilable`
 	StatusOrphaned       = `orphaned`
 	StatusStale          = `stale`
+	StatusGone           = `gone`
 )
 
-func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+func (r *ServerReconciler) Reconcile(req ctrl.Request) (result ctrl.Result, err error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("server", req.NamespacedName)
 
@@ -91,19 +134,7 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	// 2. Load a BMC client
-	if len(os.Getenv(ENV_BMC_CLIENT_ID)) <= 0 ||
-		len(os.Getenv(ENV_BMC_CLIENT_SECRET)) <= 0 ||
-		len(os.Getenv(ENV_BMC_TOKEN_URL)) <= 0 ||
-		len(os.Getenv(ENV_BMC_ENDPOINT_URL)) <= 0 {
-		log.Error(fmt.Errorf(`incomplete BMC connection configuration`), `incomplete BMC connection configuration`)
-		os.Exit(1)
-	}
-	bmcConfig := clientcredentials.Config{
-		ClientID:     os.Getenv(ENV_BMC_CLIENT_ID),
-		ClientSecret: os.Getenv(ENV_BMC_CLIENT_SECRET),
-		TokenURL:     os.Getenv(ENV_BMC_TOKEN_URL),
-		Scopes:       []string{"bmc", "bmc.read"}}
-	bmc := bmcConfig.Client(context.Background())
+	bmc := bmcClient(ctx)
 
 	// 2. Check for delettion activity and finalizer
 	if server.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -125,18 +156,37 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	} else {
 		log.Info(`finalizing`)
 
+		// Cordon or drain the Node running on the server first
+		if ready, err := r.prepareNodeForDeletion(ctx, &server); err != nil {
+			return ctrl.Result{}, err
+		} else if !ready {
+			return requeueAfter1Min, nil
+		}
+
+		if err := r.deleteDNSEndpoint(ctx, &server); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		bmcServerID := server.Annotations[bmcServerIDAnnotation]
-		// skip finalization for orphaned resources
-		if server.Status.BMCStatus != StatusOrphaned && len(bmcServerID) > 0 {
-			// Do BMC cleanup
-			apiReq, err := http.NewRequest(
-				http.MethodDelete,
-				fmt.Sprintf("%sservers/%s", os.Getenv(ENV_BMC_ENDPOINT_URL), bmcServerID),
-				nil)
+		// skip finalization for orphaned resources and servers that are already gone
+		if server.Status.BMCStatus != StatusOrphaned && server.Status.BMCStatus != StatusGone && len(bmcServerID) > 0 {
+			// Do BMC cleanup. Servers with attached IP blocks are deprovisioned
+			// instead so that the blocks outlive the server and remain owned by
+			// their IPBlock resources.
+			method := http.MethodDelete
+			url := fmt.Sprintf("%sservers/%s", os.Getenv(ENV_BMC_ENDPOINT_URL), bmcServerID)
+			var reqBody io.Reader
+			if server.Spec.Network != nil && len(server.Spec.Network.IPBlocks) > 0 {
+				method = http.MethodPost
+				url = fmt.Sprintf("%sservers/%s/actions/deprovision", os.Getenv(ENV_BMC_ENDPOINT_URL), bmcServerID)
+				reqBody = bytes.NewBufferString(`{"deleteIpBlocks":false}`)
+			}
+			apiReq, err := http.NewRequest(method, url, reqBody)
 			if err != nil {
 				r.Recorder.Event(&server, `Warning`, EventReasonCleanupError, err.Error())
 				return ctrl.Result{}, err
 			}
+			apiReq.Header.Set(`Content-Type`, `application/json`)
 			apiResp, err := bmc.Do(apiReq)
 			if err != nil {
 				r.Recorder.Event(&server, `Warning`, EventReasonCleanupError, err.Error())
@@ -167,8 +217,11 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 					return ctrl.Result{}, err
 				}
 				return requeueAfter2Min, nil
+			case 404:
+				// already deleted out of band
+				r.Recorder.Eventf(&server, `Normal`, EventReasonCleanupSuccess, "BMC server %s was already deleted", bmcServerID)
 			case 403:
-				// unauthorized (also 404)
+				// unauthorized
 				log.Info("unable to delete", `code`, 403, `body`, string(body))
 				server.Status.BMCStatus = StatusOrphaned
 				if err := r.Update(ctx, &server); err != nil {
@@ -194,6 +247,13 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			}
 		}
 
+		r.takePendingCredentials(server.UID)
+
+		// Delete a BMC server still pending from a replacement
+		if _, err := r.deleteReplacedServer(bmc, &server); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		for i, finalizer := range server.ObjectMeta.Finalizers {
 			if finalizer == finalizerName {
 				server.ObjectMeta.Finalizers[i] = server.ObjectMeta.Finalizers[len(server.ObjectMeta.Finalizers)-1]
@@ -208,11 +268,120 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 
+	// Delete expired servers and reconcile again when the server expires or
+	// an expiry warning is due
+	expired, untilNext, err := r.checkExpiry(ctx, &server)
+	if err != nil || expired {
+		return ctrl.Result{}, err
+	}
+	if untilNext > 0 {
+		defer func() {
+			result = requeueBefore(result, untilNext)
+		}()
+	}
+
 	// 3. Create, poll, or update? Branch on the bmcServerID annotation
 	bmcServerID := server.Annotations[bmcServerIDAnnotation]
 	if len(bmcServerID) == 0 {
 		log.Info(`creating`)
-		createBody, err := json.Marshal(server.Spec)
+		// Finish a replacement before creating the new server
+		if deleted, err := r.deleteReplacedServer(bmc, &server); err != nil {
+			return ctrl.Result{}, err
+		} else if deleted {
+			if err := r.Update(ctx, &server); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if budget, err := exceededBudget(ctx, r, server.Namespace); err != nil {
+			log.Info(`unable to check budgets`, `error`, err.Error())
+		} else if budget != nil {
+			r.Recorder.Eventf(&server, `Warning`, EventReasonBudgetExceeded, "BMCBudget %s is exceeded, spent %s USD of %s USD", budget.Name, budget.Status.Spent, budget.Spec.Amount)
+		}
+		if err := ensureOwnerTags(ctx); err != nil {
+			r.Recorder.Event(&server, `Warning`, EventReasonCreateError, err.Error())
+			return requeueAfter1Min, nil
+		}
+
+		// An earlier attempt may have created the server without recording
+		// its ID. Adopt that server instead of creating another one.
+		if _, ok := server.Annotations[createKeyAnnotation]; ok {
+			record, err := findCreatedServer(bmc, &server, r.ClusterID)
+			if err != nil {
+				r.Recorder.Event(&server, `Warning`, EventReasonCreateError, err.Error())
+				return requeueAfter1Min, nil
+			}
+			if record != nil {
+				r.Recorder.Eventf(&server, `Normal`, EventReasonAdopted, "Adopted BMC server %s from an earlier create attempt", record.BMCServerID)
+				server.Status = observedStatus(&server, record.ServerStatus)
+				server.Annotations[bmcServerIDAnnotation] = record.BMCServerID
+				if err := r.Update(ctx, &server); err != nil {
+					return ctrl.Result{}, err
+				}
+				return requeueAfter1Min, nil
+			}
+		} else {
+			// Record the create key before calling BMC
+			if server.Annotations == nil {
+				server.Annotations = map[string]string{}
+			}
+			server.Annotations[createKeyAnnotation] = createKey(&server)
+			if err := r.Update(ctx, &server); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		candidates := placements(&server.Spec)
+		start := server.Status.PlacementAttempt % len(candidates)
+		attempt := r.choosePlacement(candidates, start)
+		if attempt < 0 {
+			// Servers admitted before a product was withdrawn, or while the
+			// webhook used a stale catalog, wait for the product to be offered
+			r.Recorder.Eventf(&server, `Warning`, EventReasonProductNotOffered, "Server type %s is not offered in %s", server.Spec.Type, server.Spec.Location)
+			return requeueAfter5Min, nil
+		}
+		chosen := candidates[attempt]
+		if r.Catalog != nil {
+			if quantity, ok := r.Catalog.Available(chosen.Type, chosen.Location); ok && quantity <= 0 {
+				r.Recorder.Eventf(&server, `Warning`, EventReasonProductUnavailable, "Server type %s is out of stock in %s", chosen.Type, chosen.Location)
+			}
+		}
+		createReq := serverCreateRequest{
+			Hostname:              server.Spec.Hostname,
+			Description:           server.Spec.Description,
+			OS:                    server.Spec.OS,
+			Type:                  chosen.Type,
+			Location:              chosen.Location,
+			InstallDefaultSSHKeys: server.Spec.InstallDefaultSSHKeys,
+			SSHKeyIDs:             server.Spec.SSHKeyIDs,
+			NetworkType:           server.Spec.NetworkType,
+			PricingModel:          server.Spec.PricingModel,
+			Tags:                  desiredTags(&server, r.ClusterID),
+		}
+		if server.Spec.Network != nil && len(server.Spec.Network.IPBlocks) > 0 {
+			ipBlocks, err := r.resolveIPBlocks(ctx, &server)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if ipBlocks == nil {
+				// at least one referenced IPBlock has not been allocated yet
+				return requeueAfter1Min, nil
+			}
+			createReq.NetworkConfiguration = &networkConfiguration{
+				IPBlocksConfiguration: &ipBlocksConfiguration{
+					ConfigurationType: `USER_DEFINED`,
+					IPBlocks:          ipBlocks,
+				},
+			}
+		}
+		osConfig, ready, err := r.osConfiguration(ctx, &server)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !ready {
+			return requeueAfter1Min, nil
+		}
+		createReq.OSConfiguration = osConfig
+		createBody, err := json.Marshal(createReq)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -252,7 +421,7 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			// something is wrong with the controller or input, stop polling
 			return ctrl.Result{}, nil
 		case 403:
-			// unauthorized (also 404)
+			// unauthorized
 			r.Recorder.Eventf(&server, `Warning`, EventReasonCreateErrorPermanent, `Code: %v`, apiResp.StatusCode)
 			log.Info("unable to reconcile", `code`, 403, `body`, string(body))
 			server.Status.BMCStatus = StatusIrreconcilable
@@ -262,10 +431,23 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			// something is wrong with the controller or input, stop polling
 			return ctrl.Result{}, nil
 		case 406:
-			// no inventory, backoff and retry
-			r.Recorder.Eventf(&server, `Warning`, EventReasonCreateErrorInventory, `Code: %v`, apiResp.StatusCode)
+			// no inventory, try the next preferred placement or backoff and retry
+			r.Recorder.Eventf(&server, `Warning`, EventReasonCreateErrorInventory, "No inventory for %s in %s", chosen.Type, chosen.Location)
 			log.Info("temporary no inventory", `code`, 406, `body`, string(body))
-			return requeueAfter5Min, err
+			if len(candidates) <= 1 {
+				return requeueAfter5Min, nil
+			}
+			next, exhausted := nextPlacement(attempt, start, len(candidates))
+			server.Status.PlacementAttempt = next
+			if err := r.Update(ctx, &server); err != nil {
+				return ctrl.Result{}, err
+			}
+			if exhausted {
+				r.Recorder.Eventf(&server, `Warning`, EventReasonPlacementExhausted, "No inventory in any of %d preferred placements", len(candidates))
+				return requeueAfter5Min, nil
+			}
+			r.Recorder.Eventf(&server, `Normal`, EventReasonPlacementFallback, "Trying %s in %s", candidates[next].Type, candidates[next].Location)
+			return ctrl.Result{Requeue: true}, nil
 		case 409:
 			// something is wrong; incompatible state
 			r.Recorder.Eventf(&server, `Warning`, EventReasonCreateErrorPermanent, `Code: %v`, apiResp.StatusCode)
@@ -298,12 +480,30 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 
 		r.Recorder.Eventf(&server, `Normal`, EventReasonCreated, "creatd BMC server %s", ss.BMCServerID)
+		if chosen != candidates[0] {
+			r.Recorder.Eventf(&server, `Normal`, EventReasonPlacementFallback, "Server provisioned as %s in %s", chosen.Type, chosen.Location)
+		}
 
-		server.Status = ss
+		server.Status = observedStatus(&server, ss)
 		server.Annotations[bmcServerIDAnnotation] = ss.BMCServerID
+		server.Annotations[provisionedHashAnnotation] = provisionedHash(&server.Spec)
+
+		// One-time credentials are only returned now, publish them right away
+		var creds serverCredentials
+		if err := json.Unmarshal(body, &creds); err != nil {
+			log.Info(`unable to read credentials`, `error`, err.Error())
+		}
+		credentialsErr := r.publishCredentials(ctx, &server, &creds)
+		if credentialsErr != nil {
+			log.Info(`unable to write connection secret, credentials are retried on the next pass`, `error`, credentialsErr.Error())
+		}
+
 		if err := r.Update(ctx, &server); err != nil {
 			return ctrl.Result{}, err
 		}
+		if credentialsErr != nil {
+			return ctrl.Result{}, credentialsErr
+		}
 		return requeueAfter1Min, nil
 
 	} else {
@@ -352,8 +552,11 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 				return ctrl.Result{}, err
 			}
 			return requeueAfter5Min, nil
+		case 404:
+			// deleted out of band
+			return r.serverGone(ctx, &server)
 		case 403:
-			// unauthorized (also 404)
+			// unauthorized
 			r.Recorder.Event(&server, `Warning`, EventReasonResourceOrphaned, `Access to BMC resource was denied`)
 			log.Info("unable to reconcile", `code`, 403, `body`, string(body))
 			server.Status.BMCStatus = StatusOrphaned
@@ -383,24 +586,93 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return requeueAfter2Min, err
 		}
 
+		var record serverRecord
+		err = json.Unmarshal(body, &record)
+		if err != nil {
+			return requeueAfter2Min, err
+		}
+
 		// detect a status delta
 		if server.Status.BMCStatus != ss.BMCStatus {
 			r.Recorder.Eventf(&server, `Normal`, EventReasonStatusChange, `%v -> %v`, server.Status.BMCStatus, ss.BMCStatus)
 		}
 
-		server.Status = ss
+		server.Status = observedStatus(&server, ss)
+
+		if c := server.Status.GetCondition(bmcv1.ServerGone); c != nil && c.Status == corev1.ConditionTrue {
+			server.Status.SetCondition(bmcv1.ServerCondition{
+				Type:               bmcv1.ServerGone,
+				Status:             corev1.ConditionFalse,
+				LastTransitionTime: metav1.Now(),
+				Reason:             `Recreated`,
+			})
+		}
+
+		r.estimateCost(&server)
+
+		// Power the server on or off as scheduled
+		untilPowerTransition, err := r.enforcePowerSchedule(bmc, &server)
+		if err != nil {
+			log.Info(`unable to enforce power schedule`, `error`, err.Error())
+		}
+
+		// Apply spec changes permitted by the update policy
+		if updating, err := r.applyUpdate(ctx, bmc, &server, &record); err != nil {
+			log.Info(`unable to apply update`, `error`, err.Error())
+		} else if updating {
+			if err := r.Update(ctx, &server); err != nil {
+				return ctrl.Result{}, err
+			}
+			return requeueAfter1Min, nil
+		}
+
+		// Compare the live server with the spec
+		if err := r.checkDrift(ctx, bmc, &server, &record); err != nil {
+			log.Info(`unable to handle drift`, `error`, err.Error())
+		}
+
+		// Keep BMC tags in line with spec.tags and mirrored labels
+		if err := r.syncTags(bmc, &server, ss.Tags); err != nil {
+			log.Info(`unable to sync tags`, `error`, err.Error())
+		}
+
+		// Keep the connection secret in line with the current addresses
+		credentialsPending, credentialsErr := r.writePendingCredentials(ctx, &server)
+		if credentialsErr != nil {
+			log.Info(`unable to write connection secret`, `error`, credentialsErr.Error())
+		}
+
+		// Point the server Service at the current addresses
+		if err := r.reconcileService(ctx, &server); err != nil {
+			log.Info(`unable to reconcile service`, `error`, err.Error())
+		}
+
+		// Publish DNS records for the public addresses
+		if err := r.reconcileDNSEndpoint(ctx, &server); err != nil {
+			log.Info(`unable to reconcile dns endpoint`, `error`, err.Error())
+		}
+
 		if err := r.Update(ctx, &server); err != nil {
 			return requeueAfter2Min, err
 		}
+		if credentialsPending && credentialsErr != nil {
+			// one-time credentials are retried with backoff
+			return ctrl.Result{}, credentialsErr
+		}
 
 		// BMC server details are mostly immutable. However servers do have
 		// power state and can have SSH and other OS configuration, "reset."
 		// A ValidatingWebhook should prevent users or other controllers from
 		// changing a server resource.
 
-		// Poll timing based on status and expected change
-		switch ss.BMCStatus {
-		case `powered-on`:
+		// Poll timing based on status, expected change and power schedule
+		switch {
+		case untilPowerTransition > 0 && ss.BMCStatus == StatusPoweredOff && server.Status.NextPowerTransition.Action == bmcv1.PowerOn:
+			// nothing changes on a server powered off by schedule until it is powered on
+			return ctrl.Result{RequeueAfter: untilPowerTransition}, nil
+		case untilPowerTransition > 0:
+			return requeueBefore(requeueAfter2Min, untilPowerTransition), nil
+		case ss.BMCStatus == StatusPoweredOn:
 			return requeueAfter2Min, nil
 		default:
 			return requeueAfter1Min, nil
@@ -408,6 +680,108 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 }
 
+// observedStatus returns the status reported by BMC combined with the status
+// fields maintained by this and other controllers.
+func observedStatus(server *bmcv1.Server, ss bmcv1.ServerStatus) bmcv1.ServerStatus {
+	ss.NodeRef = server.Status.NodeRef
+	ss.Conditions = server.Status.Conditions
+	ss.ExpiresAt = server.Status.ExpiresAt
+	ss.ExpiryWarning = server.Status.ExpiryWarning
+	ss.NextPowerTransition = server.Status.NextPowerTransition
+	return ss
+}
+
+// serverGone handles a BMC server that was deleted outside of the controller.
+// The Gone condition is set and, when the spec asks for it, the server ID is
+// cleared so that the next reconcile provisions a new BMC server.
+func (r *ServerReconciler) serverGone(ctx context.Context, server *bmcv1.Server) (ctrl.Result, error) {
+	bmcServerID := server.Annotations[bmcServerIDAnnotation]
+	server.Status.SetCondition(bmcv1.ServerCondition{
+		Type:               bmcv1.ServerGone,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             `NotFound`,
+		Message:            fmt.Sprintf("BMC server %s no longer exists", bmcServerID),
+	})
+
+	if !server.Spec.RecreateOnLoss {
+		if server.Status.BMCStatus != StatusGone {
+			r.Recorder.Eventf(server, `Warning`, EventReasonServerGone, "BMC server %s no longer exists", bmcServerID)
+		}
+		server.Status.BMCStatus = StatusGone
+		if err := r.Update(ctx, server); err != nil {
+			return ctrl.Result{}, err
+		}
+		// nothing left to poll
+		return ctrl.Result{}, nil
+	}
+
+	r.Recorder.Eventf(server, `Warning`, EventReasonServerGone, "BMC server %s no longer exists, provisioning a new one", bmcServerID)
+	delete(server.Annotations, bmcServerIDAnnotation)
+	server.Status = observedStatus(server, bmcv1.ServerStatus{})
+	if err := r.Update(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// osConfiguration returns the OS configuration sent to BMC when the server is
+// provisioned. It returns false without error when the user data Secret is
+// not available yet.
+func (r *ServerReconciler) osConfiguration(ctx context.Context, server *bmcv1.Server) (*osConfiguration, bool, error) {
+	ref := server.Spec.UserDataSecretRef
+	if ref == nil {
+		return nil, true, nil
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: ref.Name}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(server, `Warning`, EventReasonUserDataPending, "Secret %s not found", ref.Name)
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	key := ref.Key
+	if len(key) == 0 {
+		key = `value`
+	}
+	userData, ok := secret.Data[key]
+	if !ok {
+		r.Recorder.Eventf(server, `Warning`, EventReasonUserDataPending, "Secret %s has no key %s", ref.Name, key)
+		return nil, false, nil
+	}
+	return &osConfiguration{
+		CloudInit: &cloudInit{UserData: base64.StdEncoding.EncodeToString(userData)},
+	}, true, nil
+}
+
+// resolveIPBlocks translates the IP block references in the server network
+// spec into BMC IP block IDs. It returns nil without error when a referenced
+// IPBlock resource exists but has not been allocated by BMC yet.
+func (r *ServerReconciler) resolveIPBlocks(ctx context.Context, server *bmcv1.Server) ([]ipBlockIDRef, error) {
+	var refs []ipBlockIDRef
+	for _, ref := range server.Spec.Network.IPBlocks {
+		if len(ref.ID) > 0 {
+			refs = append(refs, ipBlockIDRef{ID: ref.ID})
+			continue
+		}
+		var ipBlock bmcv1.IPBlock
+		if err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: ref.Name}, &ipBlock); err != nil {
+			if apierrors.IsNotFound(err) {
+				r.Recorder.Eventf(server, `Warning`, EventReasonIPBlockPending, "IPBlock %s not found", ref.Name)
+				return nil, nil
+			}
+			return nil, err
+		}
+		if len(ipBlock.Status.BMCIPBlockID) == 0 || ipBlock.Status.BMCStatus != IPBlockStatusUnassigned {
+			r.Recorder.Eventf(server, `Normal`, EventReasonIPBlockPending, "Waiting for IPBlock %s", ref.Name)
+			return nil, nil
+		}
+		refs = append(refs, ipBlockIDRef{ID: ipBlock.Status.BMCIPBlockID})
+	}
+	return refs, nil
+}
+
 func (r *ServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&bmcv1.Server{}).
@@ -0,0 +1,149 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// hoursPerMonth converts monthly prices to hourly rates.
+const hoursPerMonth = 730
+
+// PriceTable maps server products to hourly rates in USD.
+type PriceTable map[string]float64
+
+// priceKey identifies a server product in a PriceTable.
+func priceKey(t bmcv1.ServerType, l bmcv1.LocationID, pm bmcv1.ServerPricingModel) string {
+	if len(pm) == 0 {
+		pm = bmcv1.PMHourly
+	}
+	return string(t) + `/` + string(l) + `/` + string(pm)
+}
+
+// HourlyRate returns the hourly rate of a server product.
+func (p PriceTable) HourlyRate(t bmcv1.ServerType, l bmcv1.LocationID, pm bmcv1.ServerPricingModel) (float64, bool) {
+	rate, ok := p[priceKey(t, l, pm)]
+	return rate, ok
+}
+
+// LoadPriceTable reads a price table from a JSON file mapping keys of the form
+// type/location/pricingModel, for example s1.c1.small/PHX/HOURLY, to hourly
+// rates in USD.
+func LoadPriceTable(path string) (PriceTable, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var prices PriceTable
+	if err := json.Unmarshal(b, &prices); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// hourlyRate returns the hourly rate of a server from the configured price
+// table or, for products it does not list, from the BMC products API.
+func (r *ServerReconciler) hourlyRate(t bmcv1.ServerType, l bmcv1.LocationID, pm bmcv1.ServerPricingModel) (float64, bool) {
+	if rate, ok := r.Prices.HourlyRate(t, l, pm); ok {
+		return rate, true
+	}
+	if r.Catalog != nil {
+		return r.Catalog.HourlyRate(t, l, pm)
+	}
+	return 0, false
+}
+
+// estimateCost sets the hourly rate and the cost accrued since the server was
+// provisioned in the status of a polled server.
+func (r *ServerReconciler) estimateCost(server *bmcv1.Server) {
+	rate, ok := r.hourlyRate(server.Status.Type, server.Status.Location, server.Status.PricingModel)
+	if !ok {
+		server.Status.HourlyRate = ``
+		server.Status.EstimatedCost = ``
+		return
+	}
+	since := server.CreationTimestamp.Time
+	if server.Status.ProvisionedOn != nil {
+		since = server.Status.ProvisionedOn.Time
+	}
+	server.Status.HourlyRate = formatUSD(rate)
+	server.Status.EstimatedCost = formatUSD(rate * time.Since(since).Hours())
+}
+
+func formatUSD(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// parseUSD parses an amount formatted by formatUSD or set in a BMCBudget.
+// Unset amounts are zero.
+func parseUSD(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+var (
+	namespaceCostDesc = prometheus.NewDesc(
+		`bmc_namespace_estimated_cost_usd`,
+		`Estimated cost accrued by the BMC servers in a namespace`,
+		[]string{`namespace`}, nil)
+	namespaceRateDesc = prometheus.NewDesc(
+		`bmc_namespace_hourly_rate_usd`,
+		`Hourly rate of the BMC servers in a namespace`,
+		[]string{`namespace`}, nil)
+)
+
+// CostCollector exports the estimated cost and hourly rate of servers
+// aggregated by namespace. Values are read from the server status when
+// metrics are scraped.
+type CostCollector struct {
+	client.Reader
+}
+
+var _ prometheus.Collector = &CostCollector{}
+
+// Describe implements prometheus.Collector.
+func (c *CostCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- namespaceCostDesc
+	ch <- namespaceRateDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *CostCollector) Collect(ch chan<- prometheus.Metric) {
+	var servers bmcv1.ServerList
+	if err := c.List(context.Background(), &servers); err != nil {
+		return
+	}
+	cost := map[string]float64{}
+	rate := map[string]float64{}
+	for _, server := range servers.Items {
+		cost[server.Namespace] += parseUSD(server.Status.EstimatedCost)
+		rate[server.Namespace] += parseUSD(server.Status.HourlyRate)
+	}
+	for ns, v := range cost {
+		ch <- prometheus.MustNewConstMetric(namespaceCostDesc, prometheus.GaugeValue, v, ns)
+		ch <- prometheus.MustNewConstMetric(namespaceRateDesc, prometheus.GaugeValue, rate[ns], ns)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPriceKey(t *testing.T) {
+	tests := []struct {
+		name string
+		t    bmcv1.ServerType
+		l    bmcv1.LocationID
+		pm   bmcv1.ServerPricingModel
+		want string
+	}{
+		{`hourly`, bmcv1.S1C1Small, bmcv1.Phoenix, bmcv1.PMHourly, `s1.c1.small/PHX/HOURLY`},
+		{`unset pricing model`, bmcv1.S1C1Small, bmcv1.Phoenix, ``, `s1.c1.small/PHX/HOURLY`},
+		{`reservation`, bmcv1.D1C4Large, bmcv1.Ashburn, bmcv1.PMTwelveMonthsReservation, `d1.c4.large/ASH/TWELVE_MONTHS_RESERVATION`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := priceKey(tt.t, tt.l, tt.pm); got != tt.want {
+				t.Errorf("priceKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	prices := PriceTable{
+		`s1.c1.small/PHX/HOURLY`:                0.10,
+		`s1.c1.small/PHX/ONE_MONTH_RESERVATION`: 0.08,
+	}
+	tenHoursAgo := metav1.NewTime(time.Now().Add(-10 * time.Hour))
+	tests := []struct {
+		name     string
+		status   bmcv1.ServerStatus
+		created  metav1.Time
+		wantRate string
+		wantCost string
+	}{
+		{
+			name:     `since provisioned`,
+			status:   bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix, ProvisionedOn: &tenHoursAgo},
+			created:  metav1.NewTime(time.Now().Add(-20 * time.Hour)),
+			wantRate: `0.10`,
+			wantCost: `1.00`,
+		},
+		{
+			name:     `since created`,
+			status:   bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix},
+			created:  metav1.NewTime(time.Now().Add(-20 * time.Hour)),
+			wantRate: `0.10`,
+			wantCost: `2.00`,
+		},
+		{
+			name: `pricing model`,
+			status: bmcv1.ServerStatus{
+				Type:          bmcv1.S1C1Small,
+				Location:      bmcv1.Phoenix,
+				PricingModel:  bmcv1.PMOneMonthReservation,
+				ProvisionedOn: &tenHoursAgo,
+			},
+			wantRate: `0.08`,
+			wantCost: `0.80`,
+		},
+		{
+			name: `unknown product`,
+			status: bmcv1.ServerStatus{
+				Type:          bmcv1.S1C1Small,
+				Location:      bmcv1.Ashburn,
+				ProvisionedOn: &tenHoursAgo,
+				HourlyRate:    `0.10`,
+				EstimatedCost: `1.00`,
+			},
+		},
+	}
+	r := &ServerReconciler{Prices: prices}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &bmcv1.Server{Status: tt.status}
+			server.CreationTimestamp = tt.created
+			r.estimateCost(server)
+			if server.Status.HourlyRate != tt.wantRate || server.Status.EstimatedCost != tt.wantCost {
+				t.Errorf("estimateCost() = %q, %q, want %q, %q",
+					server.Status.HourlyRate, server.Status.EstimatedCost, tt.wantRate, tt.wantCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	EventReasonDNSUpdated = `DNSUpdated`
+
+	// dnsEndpointGVK is the external-dns DNSEndpoint resource. It is handled as
+	// unstructured content so that external-dns is not a build dependency.
+	dnsEndpointGVK = schema.GroupVersionKind{Group: `externaldns.k8s.io`, Version: `v1alpha1`, Kind: `DNSEndpoint`}
+)
+
+// dnsName returns the fully qualified record name of a server.
+func dnsName(server *bmcv1.Server) string {
+	return server.Spec.Hostname + `.` + strings.TrimSuffix(server.Spec.DNS.Zone, `.`)
+}
+
+// reconcileDNSEndpoint keeps a DNSEndpoint named after the server mapping its
+// hostname in the configured zone to the public IP addresses. The DNSEndpoint
+// is removed while spec.dns is unset or the server has no public addresses.
+func (r *ServerReconciler) reconcileDNSEndpoint(ctx context.Context, server *bmcv1.Server) error {
+	if server.Spec.DNS == nil || len(server.Status.PublicIPAddresses) == 0 {
+		return r.deleteDNSEndpoint(ctx, server)
+	}
+
+	targets := make([]interface{}, 0, len(server.Status.PublicIPAddresses))
+	for _, ip := range server.Status.PublicIPAddresses {
+		targets = append(targets, ip)
+	}
+	endpoint := map[string]interface{}{
+		`dnsName`:    dnsName(server),
+		`recordType`: `A`,
+		`targets`:    targets,
+	}
+	if server.Spec.DNS.TTL > 0 {
+		endpoint[`recordTTL`] = server.Spec.DNS.TTL
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(dnsEndpointGVK)
+	obj.SetNamespace(server.Namespace)
+	obj.SetName(server.Name)
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+		if err := unstructured.SetNestedSlice(obj.Object, []interface{}{endpoint}, `spec`, `endpoints`); err != nil {
+			return err
+		}
+		return ctrl.SetControllerReference(server, obj, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		r.Recorder.Eventf(server, `Normal`, EventReasonDNSUpdated, "%s points to %v", dnsName(server), server.Status.PublicIPAddresses)
+	}
+	return nil
+}
+
+// deleteDNSEndpoint removes the DNSEndpoint of a server so that the records
+// are withdrawn before the addresses are released. It succeeds when there is
+// nothing to delete or external-dns is not installed.
+func (r *ServerReconciler) deleteDNSEndpoint(ctx context.Context, server *bmcv1.Server) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(dnsEndpointGVK)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: server.Name}, obj); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, server) {
+		return nil
+	}
+	if err := r.Delete(ctx, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	r.Recorder.Eventf(server, `Normal`, EventReasonDNSUpdated, "Removed DNSEndpoint %s", obj.GetName())
+	return nil
+}
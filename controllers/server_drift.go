@@ -0,0 +1,199 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	EventReasonDriftDetected    = `DriftDetected`
+	EventReasonDriftResolved    = `DriftResolved`
+	EventReasonReprovisioned    = `Reprovisioned`
+	EventReasonReprovisionError = `ReprovisionError`
+)
+
+// driftedFields returns the spec fields the live BMC server no longer
+// matches. Only fields reported by the servers API are compared; SSH keys
+// are skipped when the API omits them. Keys installed because they are marked
+// as default in the account are not drift while default keys are enabled.
+func driftedFields(server *bmcv1.Server, record *serverRecord) []string {
+	var fields []string
+	if record.Hostname != server.Spec.Hostname {
+		fields = append(fields, `hostname`)
+	}
+	if record.Description != server.Spec.Description {
+		fields = append(fields, `description`)
+	}
+	if record.OS != server.Spec.OS {
+		fields = append(fields, `os`)
+	}
+	if len(server.Spec.NetworkType) > 0 && len(record.NetworkType) > 0 && record.NetworkType != server.Spec.NetworkType {
+		fields = append(fields, `networkType`)
+	}
+	installDefaults := server.Spec.InstallDefaultSSHKeys == nil || *server.Spec.InstallDefaultSSHKeys
+	if record.InstallDefaultSSHKeys != nil && *record.InstallDefaultSSHKeys != installDefaults {
+		fields = append(fields, `installDefaultSshKeys`)
+	}
+	if record.SSHKeyIDs != nil && !sshKeysMatch(server.Spec.SSHKeyIDs, record.SSHKeyIDs, installDefaults) {
+		fields = append(fields, `sshKeyIds`)
+	}
+	found := len(record.Type) == 0 || len(record.Location) == 0
+	for _, p := range placements(&server.Spec) {
+		if p.Type == record.Type && p.Location == record.Location {
+			found = true
+		}
+	}
+	if !found {
+		fields = append(fields, `type`, `location`)
+	}
+	return fields
+}
+
+// sshKeysMatch reports whether every key of the spec is installed and, unless
+// default keys are installed as well, no other key is.
+func sshKeysMatch(spec, live []string, installDefaults bool) bool {
+	for _, id := range spec {
+		if !containsString(live, id) {
+			return false
+		}
+	}
+	if installDefaults {
+		return true
+	}
+	for _, id := range live {
+		if !containsString(spec, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// reprovisionableFields reports whether every drifted field is restored by
+// reprovisioning the server.
+func reprovisionableFields(fields []string) bool {
+	for _, f := range fields {
+		switch f {
+		case `hostname`, `description`, `os`, `sshKeyIds`, `installDefaultSshKeys`:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checkDrift maintains the Drifted condition of a polled server and
+// reprovisions it when its drift policy asks for it. Servers that are being
+// provisioned or reprovisioned are not checked.
+func (r *ServerReconciler) checkDrift(ctx context.Context, bmc *http.Client, server *bmcv1.Server, record *serverRecord) error {
+	if server.Status.BMCStatus != StatusPoweredOn {
+		return nil
+	}
+	fields := driftedFields(server, record)
+	previous := server.Status.GetCondition(bmcv1.ServerDrifted)
+	if len(fields) == 0 {
+		if previous != nil && previous.Status == corev1.ConditionTrue {
+			r.Recorder.Event(server, `Normal`, EventReasonDriftResolved, `BMC server matches the spec`)
+		}
+		server.Status.SetCondition(bmcv1.ServerCondition{
+			Type:               bmcv1.ServerDrifted,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: metav1.Now(),
+			Reason:             `InSync`,
+		})
+		return nil
+	}
+
+	message := `BMC server differs from spec in ` + strings.Join(fields, `, `)
+	if previous == nil || previous.Status != corev1.ConditionTrue || previous.Message != message {
+		r.Recorder.Event(server, `Warning`, EventReasonDriftDetected, message)
+	}
+	server.Status.SetCondition(bmcv1.ServerCondition{
+		Type:               bmcv1.ServerDrifted,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             `FieldsChanged`,
+		Message:            message,
+	})
+
+	if server.Spec.DriftPolicy != bmcv1.DriftReprovision {
+		return nil
+	}
+	if !reprovisionableFields(fields) {
+		return fmt.Errorf("drifted fields can not be restored by reprovisioning: %v", fields)
+	}
+	return r.reprovision(ctx, bmc, server)
+}
+
+// reprovision reinstalls the operating system of a BMC server from its spec.
+// All data on the server is lost.
+func (r *ServerReconciler) reprovision(ctx context.Context, bmc *http.Client, server *bmcv1.Server) error {
+	osConfig, ready, err := r.osConfiguration(ctx, server)
+	if err != nil || !ready {
+		return err
+	}
+	reqBody, err := json.Marshal(serverReprovisionRequest{
+		Hostname:              server.Spec.Hostname,
+		Description:           server.Spec.Description,
+		OS:                    server.Spec.OS,
+		InstallDefaultSSHKeys: server.Spec.InstallDefaultSSHKeys,
+		SSHKeyIDs:             server.Spec.SSHKeyIDs,
+		OSConfiguration:       osConfig,
+	})
+	if err != nil {
+		return err
+	}
+	apiResp, err := bmc.Post(
+		fmt.Sprintf("%sservers/%s/actions/reprovision", os.Getenv(ENV_BMC_ENDPOINT_URL), server.Annotations[bmcServerIDAnnotation]),
+		`application/json`,
+		bytes.NewBuffer(reqBody))
+	if err != nil {
+		r.Recorder.Event(server, `Warning`, EventReasonReprovisionError, err.Error())
+		return err
+	}
+	defer apiResp.Body.Close()
+
+	switch apiResp.StatusCode {
+	case 200, 202:
+		r.Recorder.Eventf(server, `Normal`, EventReasonReprovisioned, "Reprovisioning BMC server %s", server.Annotations[bmcServerIDAnnotation])
+		server.Annotations[provisionedHashAnnotation] = provisionedHash(&server.Spec)
+		return nil
+	default:
+		r.Recorder.Eventf(server, `Warning`, EventReasonReprovisionError, `Code: %v`, apiResp.StatusCode)
+		return fmt.Errorf("unexpected response during server reprovision: %v", apiResp.StatusCode)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
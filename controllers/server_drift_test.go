@@ -0,0 +1,114 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+func TestDriftedFields(t *testing.T) {
+	const keyA, keyB, keyDefault = `5fa54d1e91867c03a0a7b4a4`, `5fa54d1e91867c03a0a7b4a5`, `5fa54d1e91867c03a0a7b4a6`
+	yes, no := true, false
+	spec := func() bmcv1.ServerSpec {
+		return bmcv1.ServerSpec{
+			Hostname:    `web-1`,
+			Description: `web`,
+			OS:          bmcv1.UbuntuBionic,
+			Type:        bmcv1.S1C1Small,
+			Location:    bmcv1.Phoenix,
+			NetworkType: bmcv1.PublicAndPrivate,
+			SSHKeyIDs:   []string{keyA},
+		}
+	}
+	record := func() serverRecord {
+		return serverRecord{
+			ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix},
+			Hostname:     `web-1`,
+			Description:  `web`,
+			OS:           bmcv1.UbuntuBionic,
+			NetworkType:  bmcv1.PublicAndPrivate,
+		}
+	}
+	tests := []struct {
+		name   string
+		spec   func(*bmcv1.ServerSpec)
+		record func(*serverRecord)
+		want   []string
+	}{
+		{`in sync`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) {}, nil},
+		{`hostname`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.Hostname = `web-2` }, []string{`hostname`}},
+		{`description`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.Description = `` }, []string{`description`}},
+		{`os`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.OS = bmcv1.CentosCentos7 }, []string{`os`}},
+		{`network type`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.NetworkType = bmcv1.PrivateOnly }, []string{`networkType`}},
+		{`network type not reported`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.NetworkType = `` }, nil},
+		{`placement`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.Location = bmcv1.Ashburn }, []string{`type`, `location`}},
+		{`placed on a preference`, func(s *bmcv1.ServerSpec) {
+			s.LocationPreferences = []bmcv1.LocationID{bmcv1.Ashburn}
+		}, func(r *serverRecord) { r.Location = bmcv1.Ashburn }, nil},
+		{`ssh keys not reported`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) { r.SSHKeyIDs = nil }, nil},
+		{`ssh keys with defaults`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) {
+			r.SSHKeyIDs = []string{keyDefault, keyA}
+		}, nil},
+		{`ssh key removed`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) {
+			r.SSHKeyIDs = []string{keyDefault}
+		}, []string{`sshKeyIds`}},
+		{`ssh key added without defaults`, func(s *bmcv1.ServerSpec) { s.InstallDefaultSSHKeys = &no }, func(r *serverRecord) {
+			r.SSHKeyIDs = []string{keyA, keyB}
+		}, []string{`sshKeyIds`}},
+		{`ssh keys without defaults`, func(s *bmcv1.ServerSpec) { s.InstallDefaultSSHKeys = &no }, func(r *serverRecord) {
+			r.SSHKeyIDs = []string{keyA}
+			r.InstallDefaultSSHKeys = &no
+		}, nil},
+		{`default keys disabled`, func(s *bmcv1.ServerSpec) { s.InstallDefaultSSHKeys = &yes }, func(r *serverRecord) {
+			r.InstallDefaultSSHKeys = &no
+		}, []string{`installDefaultSshKeys`}},
+		{`default keys enabled by default`, func(s *bmcv1.ServerSpec) {}, func(r *serverRecord) {
+			r.InstallDefaultSSHKeys = &yes
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &bmcv1.Server{Spec: spec()}
+			tt.spec(&server.Spec)
+			r := record()
+			tt.record(&r)
+			if got := driftedFields(server, &r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("driftedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReprovisionableFields(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   bool
+	}{
+		{nil, true},
+		{[]string{`hostname`, `os`, `sshKeyIds`}, true},
+		{[]string{`hostname`, `networkType`}, false},
+		{[]string{`type`, `location`}, false},
+	}
+	for _, tt := range tests {
+		if got := reprovisionableFields(tt.fields); got != tt.want {
+			t.Errorf("reprovisionableFields(%v) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	EventReasonExpiring = `Expiring`
+	EventReasonExpired  = `Expired`
+
+	// DefaultExpiryWarnings are the lead times at which expiry warnings are
+	// emitted when none are configured.
+	DefaultExpiryWarnings = []time.Duration{24 * time.Hour, time.Hour}
+)
+
+// expiryTime returns when a server expires and whether it expires at all.
+func expiryTime(server *bmcv1.Server) (time.Time, bool) {
+	var expires time.Time
+	if server.Spec.TTL != nil {
+		expires = server.CreationTimestamp.Add(server.Spec.TTL.Duration)
+	}
+	if server.Spec.ExpiresAt != nil && (expires.IsZero() || server.Spec.ExpiresAt.Time.Before(expires)) {
+		expires = server.Spec.ExpiresAt.Time
+	}
+	return expires, !expires.IsZero()
+}
+
+// checkExpiry deletes an expired server and emits a warning event for each
+// configured lead time the server enters. It reports whether the server was
+// deleted and otherwise how long until it expires or the next warning is due.
+func (r *ServerReconciler) checkExpiry(ctx context.Context, server *bmcv1.Server) (bool, time.Duration, error) {
+	expires, ok := expiryTime(server)
+	if !ok {
+		server.Status.ExpiresAt = nil
+		server.Status.ExpiryWarning = nil
+		return false, 0, nil
+	}
+
+	remaining := time.Until(expires)
+	if remaining <= 0 {
+		r.Recorder.Eventf(server, `Normal`, EventReasonExpired, "Server expired at %s", expires.Format(time.RFC3339))
+		if err := r.Delete(ctx, server); err != nil {
+			return false, 0, client.IgnoreNotFound(err)
+		}
+		return true, 0, nil
+	}
+
+	server.Status.ExpiresAt = &metav1.Time{Time: expires}
+
+	warnings := r.ExpiryWarnings
+	if warnings == nil {
+		warnings = DefaultExpiryWarnings
+	}
+	untilNext := remaining
+	var due *time.Duration
+	for i, lead := range warnings {
+		if remaining > lead {
+			// not due yet
+			if remaining-lead < untilNext {
+				untilNext = remaining - lead
+			}
+		} else if due == nil || lead < *due {
+			due = &warnings[i]
+		}
+	}
+
+	last := server.Status.ExpiryWarning
+	switch {
+	case due != nil && (last == nil || *due < last.Duration):
+		r.Recorder.Eventf(server, `Warning`, EventReasonExpiring, "Server expires in %s at %s", duration.HumanDuration(remaining), expires.Format(time.RFC3339))
+		server.Status.ExpiryWarning = &metav1.Duration{Duration: *due}
+	case due == nil && last != nil:
+		// the lifetime was extended
+		server.Status.ExpiryWarning = nil
+	default:
+		return false, untilNext, nil
+	}
+	// record the warning right away so that it is sent only once
+	if err := r.Update(ctx, server); err != nil {
+		return false, 0, err
+	}
+	return false, untilNext, nil
+}
+
+// requeueBefore shortens a result so that the request is reconciled again
+// within d.
+func requeueBefore(result ctrl.Result, d time.Duration) ctrl.Result {
+	if result.Requeue && result.RequeueAfter == 0 {
+		return result
+	}
+	if result.RequeueAfter == 0 || result.RequeueAfter > d {
+		result.RequeueAfter = d
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	// createKeyAnnotation records the idempotency key of a server create
+	// before the BMC call is made. A server with the annotation but without a
+	// BMC server ID may have been created by an attempt whose result was lost.
+	createKeyAnnotation = `bmc.api.phoenixnap.com/create_key`
+
+	// ownerTagName is the BMC tag holding the UID of the Server resource
+	// that created a BMC server.
+	ownerTagName = `k8s-bmc-uid`
+
+	// clusterTagName is the BMC tag holding the ID of the cluster whose
+	// controller created a BMC server. Servers of other clusters sharing the
+	// BMC account carry a different ID.
+	clusterTagName = `k8s-bmc-cluster`
+
+	// namespaceTagName is the BMC tag holding the namespace of the Server
+	// resource. Events about orphaned servers are recorded on the namespace.
+	namespaceTagName = `k8s-bmc-namespace`
+
+	// ownerTags are created on the BMC account before servers are tagged.
+	ownerTags = []tagRequest{
+		{Name: ownerTagName, Description: `UID of the Kubernetes Server resource managing this server`},
+		{Name: clusterTagName, Description: `ID of the Kubernetes cluster managing this server`},
+		{Name: namespaceTagName, Description: `Namespace of the Kubernetes Server resource managing this server`},
+	}
+
+	EventReasonAdopted = `Adopted`
+
+	ownerTagMu    sync.Mutex
+	ownerTagReady = map[string]bool{}
+)
+
+// createKey returns the idempotency key of the current server create. It is
+// the UID of the server until the server is replaced.
+func createKey(server *bmcv1.Server) string {
+	if key := server.Annotations[createKeyAnnotation]; len(key) > 0 {
+		return key
+	}
+	return string(server.UID)
+}
+
+// ensureOwnerTags creates the BMC owner and cluster tags unless they already
+// exist. BMC servers can only be tagged with existing tags.
+func ensureOwnerTags(ctx context.Context) error {
+	ownerTagMu.Lock()
+	defer ownerTagMu.Unlock()
+	for _, tag := range ownerTags {
+		if ownerTagReady[tag.Name] {
+			continue
+		}
+		if err := ensureTag(ctx, tag); err != nil {
+			return err
+		}
+		ownerTagReady[tag.Name] = true
+	}
+	return nil
+}
+
+// ensureTag creates a BMC tag unless a tag with the same name exists.
+func ensureTag(ctx context.Context, tag tagRequest) error {
+	bmc := bmcClient(ctx, "tags", "tags.read")
+	endpoint := endpointURL(ENV_BMC_TAGS_ENDPOINT_URL, defaultTagsEndpointURL)
+	apiResp, err := bmc.Get(fmt.Sprintf("%stags?name=%s", endpoint, url.QueryEscape(tag.Name)))
+	if err != nil {
+		return err
+	}
+	defer apiResp.Body.Close()
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		return err
+	}
+	if apiResp.StatusCode != 200 {
+		return fmt.Errorf("unexpected response during tag lookup: %v", apiResp.StatusCode)
+	}
+	var existing []tagRequest
+	if err := json.Unmarshal(body, &existing); err != nil {
+		return err
+	}
+	for _, t := range existing {
+		if t.Name == tag.Name {
+			return nil
+		}
+	}
+
+	reqBody, err := json.Marshal(tag)
+	if err != nil {
+		return err
+	}
+	createResp, err := bmc.Post(fmt.Sprintf("%stags", endpoint), `application/json`, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	defer createResp.Body.Close()
+	switch createResp.StatusCode {
+	case 200, 201:
+		return nil
+	default:
+		return fmt.Errorf("unexpected response during tag create: %v", createResp.StatusCode)
+	}
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
+// DefaultClusterID returns the UID of the kube-system namespace, which
+// identifies the cluster for as long as it exists.
+func DefaultClusterID(ctx context.Context, c client.Reader) (string, error) {
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: metav1.NamespaceSystem}, &ns); err != nil {
+		return ``, err
+	}
+	return string(ns.UID), nil
+}
+
+// findCreatedServer looks for a BMC server created by an earlier attempt to
+// create the server. Only servers carrying the create key in the owner tag
+// and the ID of this cluster in the cluster tag match, so servers the
+// controller did not create are never adopted.
+func findCreatedServer(bmc *http.Client, server *bmcv1.Server, clusterID string) (*serverRecord, error) {
+	records, err := listServers(bmc)
+	if err != nil {
+		return nil, err
+	}
+	key := createKey(server)
+	for i := range records {
+		owner, tagged := tagValue(records[i].Tags, ownerTagName)
+		if !tagged || owner != key {
+			continue
+		}
+		if cluster, ok := tagValue(records[i].Tags, clusterTagName); ok && cluster == clusterID {
+			return &records[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// tagValue returns the value of a tag and whether it is present.
+func tagValue(tags []bmcv1.ServerTag, name string) (string, bool) {
+	for _, t := range tags {
+		if t.Name == name {
+			return t.Value, true
+		}
+	}
+	return ``, false
+}
@@ -0,0 +1,99 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+func TestFindCreatedServer(t *testing.T) {
+	const clusterID = `cluster-a`
+	record := func(id string, tags ...bmcv1.ServerTag) serverRecord {
+		return serverRecord{ServerStatus: bmcv1.ServerStatus{BMCServerID: id, Tags: tags}}
+	}
+	owner := func(key string) bmcv1.ServerTag { return bmcv1.ServerTag{Name: ownerTagName, Value: key} }
+	cluster := func(id string) bmcv1.ServerTag { return bmcv1.ServerTag{Name: clusterTagName, Value: id} }
+
+	tests := []struct {
+		name    string
+		records []serverRecord
+		want    string
+	}{
+		{
+			name:    `created by an earlier attempt`,
+			records: []serverRecord{record(`other`, owner(`key-2`), cluster(clusterID)), record(`created`, owner(`key-1`), cluster(clusterID))},
+			want:    `created`,
+		},
+		{
+			name:    `untagged`,
+			records: []serverRecord{record(`untagged`)},
+		},
+		{
+			name:    `other tags only`,
+			records: []serverRecord{record(`tagged`, bmcv1.ServerTag{Name: `team`, Value: `key-1`}, cluster(clusterID))},
+		},
+		{
+			name:    `other server of this cluster`,
+			records: []serverRecord{record(`other`, owner(`key-2`), cluster(clusterID))},
+		},
+		{
+			name:    `same key in another cluster`,
+			records: []serverRecord{record(`foreign`, owner(`key-1`), cluster(`cluster-b`))},
+		},
+		{
+			name:    `without cluster tag`,
+			records: []serverRecord{record(`legacy`, owner(`key-1`))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != `/servers` {
+					http.NotFound(w, req)
+					return
+				}
+				json.NewEncoder(w).Encode(tt.records)
+			}))
+			defer api.Close()
+			defer os.Setenv(ENV_BMC_ENDPOINT_URL, os.Getenv(ENV_BMC_ENDPOINT_URL))
+			os.Setenv(ENV_BMC_ENDPOINT_URL, api.URL+`/`)
+
+			server := &bmcv1.Server{ObjectMeta: metav1.ObjectMeta{
+				UID:         `uid-1`,
+				Annotations: map[string]string{createKeyAnnotation: `key-1`},
+			}}
+			got, err := findCreatedServer(api.Client(), server, clusterID)
+			if err != nil {
+				t.Fatalf("findCreatedServer() error = %v", err)
+			}
+			switch {
+			case got == nil && len(tt.want) > 0:
+				t.Errorf("findCreatedServer() = nil, want %s", tt.want)
+			case got != nil && got.BMCServerID != tt.want:
+				t.Errorf("findCreatedServer() = %s, want %q", got.BMCServerID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+
+var (
+	EventReasonNodeCordoned = `NodeCordoned`
+	EventReasonNodeDraining = `NodeDraining`
+	EventReasonNodeDrained  = `NodeDrained`
+)
+
+// prepareNodeForDeletion applies the node deletion policy of a server to its
+// linked Node. It returns true once the BMC server may be deleted.
+func (r *ServerReconciler) prepareNodeForDeletion(ctx context.Context, server *bmcv1.Server) (bool, error) {
+	policy := server.Spec.NodeDeletionPolicy
+	if len(policy) == 0 || policy == bmcv1.NodeDeletionNone || server.Status.NodeRef == nil {
+		return true, nil
+	}
+
+	var node corev1.Node
+	if err := r.Get(ctx, types.NamespacedName{Name: server.Status.NodeRef.Name}, &node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if !node.Spec.Unschedulable {
+		patch := client.MergeFrom(node.DeepCopy())
+		node.Spec.Unschedulable = true
+		if err := r.Patch(ctx, &node, patch); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(server, `Normal`, EventReasonNodeCordoned, "Cordoned node %s", node.Name)
+	}
+	if policy != bmcv1.NodeDeletionDrain {
+		return true, nil
+	}
+	if r.KubeClient == nil {
+		return false, fmt.Errorf(`draining nodes requires a Kubernetes clientset`)
+	}
+
+	pods, err := r.KubeClient.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", node.Name),
+	})
+	if err != nil {
+		return false, err
+	}
+	remaining := 0
+	for _, pod := range pods.Items {
+		if !evictable(&pod) {
+			continue
+		}
+		remaining++
+		if pod.ObjectMeta.DeletionTimestamp != nil {
+			continue
+		}
+		err := r.KubeClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(&policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+		case apierrors.IsTooManyRequests(err):
+			// blocked by a PodDisruptionBudget, retry later
+		default:
+			return false, err
+		}
+	}
+	if remaining > 0 {
+		r.Recorder.Eventf(server, `Normal`, EventReasonNodeDraining, "Waiting for %d pods to leave node %s", remaining, node.Name)
+		return false, nil
+	}
+	r.Recorder.Eventf(server, `Normal`, EventReasonNodeDrained, "Drained node %s", node.Name)
+	return true, nil
+}
+
+// evictable reports whether a pod has to leave a Node being drained. Mirror
+// pods, DaemonSet pods and finished pods are left alone.
+func evictable(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == `DaemonSet` {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	EventReasonPlacementFallback  = `PlacementFallback`
+	EventReasonPlacementExhausted = `PlacementExhausted`
+)
+
+// placement is a server type and location combination tried when creating a
+// server.
+type placement struct {
+	Type     bmcv1.ServerType
+	Location bmcv1.LocationID
+}
+
+// placements returns the type and location combinations acceptable for a
+// server in order of preference. The spec type and location come first and
+// every type is tried in a location before moving to the next location.
+func placements(spec *bmcv1.ServerSpec) []placement {
+	types := []bmcv1.ServerType{spec.Type}
+	for _, t := range spec.TypePreferences {
+		if !containsServerType(types, t) {
+			types = append(types, t)
+		}
+	}
+	locations := []bmcv1.LocationID{spec.Location}
+	for _, l := range spec.LocationPreferences {
+		if !containsLocationID(locations, l) {
+			locations = append(locations, l)
+		}
+	}
+
+	var result []placement
+	for _, l := range locations {
+		for _, t := range types {
+			result = append(result, placement{Type: t, Location: l})
+		}
+	}
+	return result
+}
+
+// choosePlacement returns the index of the first candidate, starting from
+// start, that the catalog reports as offered and in stock. When nothing is in
+// stock the first offered candidate is returned so that BMC decides. It
+// returns -1 when no candidate is offered.
+func (r *ServerReconciler) choosePlacement(candidates []placement, start int) int {
+	if r.Catalog == nil {
+		return start
+	}
+	offered := -1
+	for i := range candidates {
+		j := (start + i) % len(candidates)
+		c := candidates[j]
+		if ok, known := r.Catalog.Offered(c.Type, c.Location); known && !ok {
+			continue
+		}
+		if offered < 0 {
+			offered = j
+		}
+		if quantity, ok := r.Catalog.Available(c.Type, c.Location); !ok || quantity > 0 {
+			return j
+		}
+	}
+	return offered
+}
+
+// nextPlacement returns the candidate to try after the attempted one ran out
+// of inventory. It reports whether every candidate has been tried since the
+// pass that started at start, in which case the rotation starts over.
+func nextPlacement(attempt, start, n int) (int, bool) {
+	next := (attempt + 1) % n
+	return next, next <= start
+}
+
+func containsServerType(list []bmcv1.ServerType, t bmcv1.ServerType) bool {
+	for _, v := range list {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLocationID(list []bmcv1.LocationID, l bmcv1.LocationID) bool {
+	for _, v := range list {
+		if v == l {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+func TestPlacements(t *testing.T) {
+	tests := []struct {
+		name string
+		spec bmcv1.ServerSpec
+		want []placement
+	}{
+		{
+			name: `no preferences`,
+			spec: bmcv1.ServerSpec{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix},
+			want: []placement{{bmcv1.S1C1Small, bmcv1.Phoenix}},
+		},
+		{
+			name: `types before locations`,
+			spec: bmcv1.ServerSpec{
+				Type:                bmcv1.S1C1Small,
+				Location:            bmcv1.Phoenix,
+				TypePreferences:     []bmcv1.ServerType{bmcv1.S1C1Medium},
+				LocationPreferences: []bmcv1.LocationID{bmcv1.Ashburn},
+			},
+			want: []placement{
+				{bmcv1.S1C1Small, bmcv1.Phoenix},
+				{bmcv1.S1C1Medium, bmcv1.Phoenix},
+				{bmcv1.S1C1Small, bmcv1.Ashburn},
+				{bmcv1.S1C1Medium, bmcv1.Ashburn},
+			},
+		},
+		{
+			name: `duplicates dropped`,
+			spec: bmcv1.ServerSpec{
+				Type:                bmcv1.S1C1Small,
+				Location:            bmcv1.Phoenix,
+				TypePreferences:     []bmcv1.ServerType{bmcv1.S1C1Small, bmcv1.S1C1Medium, bmcv1.S1C1Medium},
+				LocationPreferences: []bmcv1.LocationID{bmcv1.Phoenix},
+			},
+			want: []placement{
+				{bmcv1.S1C1Small, bmcv1.Phoenix},
+				{bmcv1.S1C1Medium, bmcv1.Phoenix},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := placements(&tt.spec); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("placements() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChoosePlacement(t *testing.T) {
+	candidates := []placement{
+		{bmcv1.S1C1Small, bmcv1.Phoenix},
+		{bmcv1.S1C1Medium, bmcv1.Phoenix},
+		{bmcv1.S1C1Small, bmcv1.Ashburn},
+	}
+	tests := []struct {
+		name      string
+		available map[bmcv1.ServerType]map[bmcv1.LocationID]int
+		start     int
+		want      int
+	}{
+		{
+			name: `first in stock`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Phoenix: 3, bmcv1.Ashburn: 1},
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 1},
+			},
+			want: 0,
+		},
+		{
+			name: `out of stock skipped`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Phoenix: 0, bmcv1.Ashburn: 1},
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 0},
+			},
+			want: 2,
+		},
+		{
+			name: `not offered skipped`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Ashburn: 1},
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 1},
+			},
+			want: 1,
+		},
+		{
+			name: `rotation starts at the attempt`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Phoenix: 3, bmcv1.Ashburn: 1},
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 1},
+			},
+			start: 1,
+			want:  1,
+		},
+		{
+			name: `rotation wraps around`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Phoenix: 3, bmcv1.Ashburn: 0},
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 0},
+			},
+			start: 1,
+			want:  0,
+		},
+		{
+			name: `nothing in stock falls back to the first offered`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Ashburn: 0},
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 0},
+			},
+			want: 1,
+		},
+		{
+			name: `unknown types are left to BMC`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Medium: {bmcv1.Phoenix: 0},
+			},
+			want: 0,
+		},
+		{
+			name: `nothing offered`,
+			available: map[bmcv1.ServerType]map[bmcv1.LocationID]int{
+				bmcv1.S1C1Small:  {bmcv1.Singapore: 1},
+				bmcv1.S1C1Medium: {bmcv1.Singapore: 1},
+			},
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ServerReconciler{Catalog: &BMCCatalog{
+				available: tt.available,
+				nextFetch: time.Now().Add(time.Hour),
+			}}
+			if got := r.choosePlacement(candidates, tt.start); got != tt.want {
+				t.Errorf("choosePlacement() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// without a catalog the attempt is used as is
+	if got := (&ServerReconciler{}).choosePlacement(candidates, 2); got != 2 {
+		t.Errorf("choosePlacement() without catalog = %d, want 2", got)
+	}
+}
+
+func TestNextPlacement(t *testing.T) {
+	tests := []struct {
+		name          string
+		attempt       int
+		start         int
+		n             int
+		want          int
+		wantExhausted bool
+	}{
+		{`next candidate`, 0, 0, 3, 1, false},
+		{`skipped candidates`, 1, 0, 4, 2, false},
+		{`last candidate`, 2, 0, 3, 0, true},
+		{`wrapped past start`, 0, 1, 3, 1, true},
+		{`rotation from a later start`, 2, 1, 4, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exhausted := nextPlacement(tt.attempt, tt.start, tt.n)
+			if got != tt.want || exhausted != tt.wantExhausted {
+				t.Errorf("nextPlacement() = %d, %v, want %d, %v", got, exhausted, tt.want, tt.wantExhausted)
+			}
+		})
+	}
+}
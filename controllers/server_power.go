@@ -0,0 +1,78 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	StatusPoweredOff = `powered-off`
+
+	EventReasonPowerScheduled = `PowerScheduled`
+	EventReasonPowerError     = `PowerActionError`
+)
+
+// enforcePowerSchedule powers a server on or off as required by its power
+// schedule and records the next transition in status. It returns the time
+// until the next transition, or zero when the server has no schedule.
+func (r *ServerReconciler) enforcePowerSchedule(bmc *http.Client, server *bmcv1.Server) (time.Duration, error) {
+	schedule := server.Spec.PowerSchedule
+	if schedule == nil {
+		server.Status.NextPowerTransition = nil
+		return 0, nil
+	}
+	next, err := schedule.NextTransition(time.Now())
+	if err != nil {
+		server.Status.NextPowerTransition = nil
+		return 0, err
+	}
+	server.Status.NextPowerTransition = &next
+	untilNext := time.Until(next.Time.Time)
+
+	// the server should be on until the next off transition
+	var action string
+	switch {
+	case next.Action == bmcv1.PowerOff && server.Status.BMCStatus == StatusPoweredOff:
+		action = `power-on`
+	case next.Action == bmcv1.PowerOn && server.Status.BMCStatus == StatusPoweredOn:
+		action = `shutdown`
+	default:
+		return untilNext, nil
+	}
+
+	bmcServerID := server.Annotations[bmcServerIDAnnotation]
+	apiResp, err := bmc.Post(fmt.Sprintf("%sservers/%s/actions/%s", os.Getenv(ENV_BMC_ENDPOINT_URL), bmcServerID, action), `application/json`, nil)
+	if err != nil {
+		r.Recorder.Event(server, `Warning`, EventReasonPowerError, err.Error())
+		return untilNext, err
+	}
+	defer apiResp.Body.Close()
+	switch apiResp.StatusCode {
+	case 200, 202:
+		r.Recorder.Eventf(server, `Normal`, EventReasonPowerScheduled, "Requested %s of BMC server %s, next transition %s at %s", action, bmcServerID, next.Action, next.Time.Format(time.RFC3339))
+		return untilNext, nil
+	default:
+		r.Recorder.Eventf(server, `Warning`, EventReasonPowerError, `Code: %v`, apiResp.StatusCode)
+		return untilNext, fmt.Errorf("unexpected response during server %s: %v", action, apiResp.StatusCode)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// Keys of the connection secret.
+var (
+	ConnectionSecretServerIDKey           = `serverId`
+	ConnectionSecretHostnameKey           = `hostname`
+	ConnectionSecretPublicIPKey           = `publicIp`
+	ConnectionSecretPrivateIPKey          = `privateIp`
+	ConnectionSecretPublicIPAddressesKey  = `publicIpAddresses`
+	ConnectionSecretPrivateIPAddressesKey = `privateIpAddresses`
+	ConnectionSecretPasswordKey           = `password`
+	ConnectionSecretRootPasswordKey       = `rootPassword`
+	ConnectionSecretManagementUIURLKey    = `managementUiUrl`
+
+	EventReasonConnectionSecretWritten  = `ConnectionSecretWritten`
+	EventReasonConnectionSecretConflict = `ConnectionSecretConflict`
+
+	errSecretNotControlled = errors.New(`secret is not controlled by the server`)
+)
+
+// writeConnectionSecret creates or updates the secret named by
+// spec.writeConnectionSecretToRef. Credentials are only available right after
+// creation, so existing credential keys are preserved when creds is nil. A
+// Secret of the same name that the server does not control is left alone.
+func (r *ServerReconciler) writeConnectionSecret(ctx context.Context, server *bmcv1.Server, creds *serverCredentials) error {
+	ref := server.Spec.WriteConnectionSecretToRef
+	if ref == nil || len(ref.Name) == 0 {
+		return nil
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Namespace: server.Namespace}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if !secret.CreationTimestamp.IsZero() && !metav1.IsControlledBy(secret, server) {
+			return errSecretNotControlled
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data[ConnectionSecretServerIDKey] = []byte(server.Annotations[bmcServerIDAnnotation])
+		secret.Data[ConnectionSecretHostnameKey] = []byte(server.Spec.Hostname)
+		secret.Data[ConnectionSecretPublicIPAddressesKey] = []byte(strings.Join(server.Status.PublicIPAddresses, `,`))
+		secret.Data[ConnectionSecretPrivateIPAddressesKey] = []byte(strings.Join(server.Status.PrivateIPAddresses, `,`))
+		secret.Data[ConnectionSecretPublicIPKey] = []byte(firstIP(server.Status.PublicIPAddresses))
+		secret.Data[ConnectionSecretPrivateIPKey] = []byte(firstIP(server.Status.PrivateIPAddresses))
+		if creds != nil {
+			setIfPresent(secret.Data, ConnectionSecretPasswordKey, creds.Password)
+			setIfPresent(secret.Data, ConnectionSecretRootPasswordKey, creds.OSConfiguration.RootPassword)
+			setIfPresent(secret.Data, ConnectionSecretManagementUIURLKey, creds.OSConfiguration.ManagementUIURL)
+		}
+		return ctrl.SetControllerReference(server, secret, r.Scheme)
+	})
+	if err == errSecretNotControlled {
+		r.Recorder.Eventf(server, `Warning`, EventReasonConnectionSecretConflict, "Secret %s exists and is not controlled by this server", ref.Name)
+		return err
+	}
+	if err != nil {
+		r.Recorder.Eventf(server, `Warning`, EventReasonConnectionSecretWritten, "Unable to write secret %s: %v", ref.Name, err)
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		r.Recorder.Eventf(server, `Normal`, EventReasonConnectionSecretWritten, "Secret %s %s", ref.Name, op)
+	}
+	return nil
+}
+
+// credentialsSecretName returns the name of the Secret holding the one-time
+// credentials of a server until they are written to the connection secret.
+func credentialsSecretName(server *bmcv1.Server) string {
+	return server.Name + `-bmc-credentials`
+}
+
+// publishCredentials writes the one-time credentials returned by a server
+// create to the connection secret. The credentials are first stored in a
+// Secret controlled by the server, which is removed once the connection
+// secret holds them, so that a failed write can be retried on a later pass
+// even after a restart. Only when that Secret cannot be written either are
+// the credentials kept in memory.
+func (r *ServerReconciler) publishCredentials(ctx context.Context, server *bmcv1.Server, creds *serverCredentials) error {
+	ref := server.Spec.WriteConnectionSecretToRef
+	if ref == nil || len(ref.Name) == 0 {
+		return nil
+	}
+	if err := r.stashCredentials(ctx, server, creds); err != nil {
+		r.credentialsMu.Lock()
+		if r.pendingCredentials == nil {
+			r.pendingCredentials = map[types.UID]*serverCredentials{}
+		}
+		r.pendingCredentials[server.UID] = creds
+		r.credentialsMu.Unlock()
+		if err := r.writeConnectionSecret(ctx, server, creds); err != nil {
+			return err
+		}
+		r.takePendingCredentials(server.UID)
+		return nil
+	}
+	if err := r.writeConnectionSecret(ctx, server, creds); err != nil {
+		return err
+	}
+	return r.clearCredentials(ctx, server)
+}
+
+// writePendingCredentials writes the connection secret including any
+// credentials left over from a failed write. It reports whether credentials
+// are still waiting to be written.
+func (r *ServerReconciler) writePendingCredentials(ctx context.Context, server *bmcv1.Server) (bool, error) {
+	creds, err := r.pendingCredentialsOf(ctx, server)
+	if err != nil {
+		return true, err
+	}
+	if err := r.writeConnectionSecret(ctx, server, creds); err != nil {
+		return creds != nil, err
+	}
+	if creds == nil {
+		return false, nil
+	}
+	r.takePendingCredentials(server.UID)
+	return false, r.clearCredentials(ctx, server)
+}
+
+// stashCredentials stores the credentials in a Secret controlled by the
+// server.
+func (r *ServerReconciler) stashCredentials(ctx context.Context, server *bmcv1.Server, creds *serverCredentials) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: credentialsSecretName(server), Namespace: server.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if !secret.CreationTimestamp.IsZero() && !metav1.IsControlledBy(secret, server) {
+			return errSecretNotControlled
+		}
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{}
+		setIfPresent(secret.Data, ConnectionSecretPasswordKey, creds.Password)
+		setIfPresent(secret.Data, ConnectionSecretRootPasswordKey, creds.OSConfiguration.RootPassword)
+		setIfPresent(secret.Data, ConnectionSecretManagementUIURLKey, creds.OSConfiguration.ManagementUIURL)
+		return ctrl.SetControllerReference(server, secret, r.Scheme)
+	})
+	return err
+}
+
+// pendingCredentialsOf returns the credentials of the server that have not
+// been written to the connection secret yet, or nil.
+func (r *ServerReconciler) pendingCredentialsOf(ctx context.Context, server *bmcv1.Server) (*serverCredentials, error) {
+	r.credentialsMu.Lock()
+	creds := r.pendingCredentials[server.UID]
+	r.credentialsMu.Unlock()
+	if creds != nil {
+		return creds, nil
+	}
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: credentialsSecretName(server)}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !metav1.IsControlledBy(&secret, server) {
+		return nil, nil
+	}
+	creds = &serverCredentials{Password: string(secret.Data[ConnectionSecretPasswordKey])}
+	creds.OSConfiguration.RootPassword = string(secret.Data[ConnectionSecretRootPasswordKey])
+	creds.OSConfiguration.ManagementUIURL = string(secret.Data[ConnectionSecretManagementUIURLKey])
+	return creds, nil
+}
+
+// clearCredentials removes the stored credentials of the server once they
+// have been written to the connection secret.
+func (r *ServerReconciler) clearCredentials(ctx context.Context, server *bmcv1.Server) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: credentialsSecretName(server), Namespace: server.Namespace}}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(secret, server) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, secret))
+}
+
+// takePendingCredentials returns the credentials of the server kept in
+// memory, or nil.
+func (r *ServerReconciler) takePendingCredentials(uid types.UID) *serverCredentials {
+	r.credentialsMu.Lock()
+	defer r.credentialsMu.Unlock()
+	creds := r.pendingCredentials[uid]
+	delete(r.pendingCredentials, uid)
+	return creds
+}
+
+func firstIP(ips []string) string {
+	if len(ips) == 0 {
+		return ``
+	}
+	return ips[0]
+}
+
+func setIfPresent(data map[string][]byte, key, value string) {
+	if len(value) > 0 {
+		data[key] = []byte(value)
+	}
+}
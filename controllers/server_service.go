@@ -0,0 +1,104 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	EventReasonServiceUpdated = `ServiceUpdated`
+)
+
+// reconcileService manages the headless Service and Endpoints described by
+// spec.service. Endpoint slices are mirrored from the Endpoints by Kubernetes.
+func (r *ServerReconciler) reconcileService(ctx context.Context, server *bmcv1.Server) error {
+	spec := server.Spec.Service
+	if spec == nil {
+		return nil
+	}
+	name := spec.Name
+	if len(name) == 0 {
+		name = server.Name
+	}
+
+	var servicePorts []corev1.ServicePort
+	var endpointPorts []corev1.EndpointPort
+	for _, p := range spec.Ports {
+		protocol := p.Protocol
+		if len(protocol) == 0 {
+			protocol = corev1.ProtocolTCP
+		}
+		servicePorts = append(servicePorts, corev1.ServicePort{Name: p.Name, Port: p.Port, Protocol: protocol})
+		endpointPorts = append(endpointPorts, corev1.EndpointPort{Name: p.Name, Port: p.Port, Protocol: protocol})
+	}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: server.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+		service.Spec.Selector = nil
+		service.Spec.Ports = servicePorts
+		return ctrl.SetControllerReference(server, service, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	ips := server.Status.PrivateIPAddresses
+	if spec.AddressType == bmcv1.ServiceAddressPublic {
+		ips = server.Status.PublicIPAddresses
+	}
+	hostname := endpointHostname(server.Spec.Hostname)
+	var addresses []corev1.EndpointAddress
+	for _, ip := range ips {
+		addresses = append(addresses, corev1.EndpointAddress{IP: ip, Hostname: hostname})
+	}
+
+	endpoints := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: server.Namespace}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, endpoints, func() error {
+		endpoints.Subsets = nil
+		if len(addresses) > 0 {
+			endpoints.Subsets = []corev1.EndpointSubset{{Addresses: addresses, Ports: endpointPorts}}
+		}
+		return ctrl.SetControllerReference(server, endpoints, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if op == controllerutil.OperationResultUpdated {
+		r.Recorder.Eventf(server, `Normal`, EventReasonServiceUpdated, "Endpoints %s now point to %v", name, ips)
+	}
+	return nil
+}
+
+// endpointHostname returns the first label of the server hostname, which is
+// used as the endpoint hostname when it is a valid DNS-1123 label.
+func endpointHostname(hostname string) string {
+	label := strings.ToLower(strings.SplitN(hostname, `.`, 2)[0])
+	if len(validation.IsDNS1123Label(label)) > 0 {
+		return ``
+	}
+	return label
+}
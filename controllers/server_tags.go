@@ -0,0 +1,129 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	EventReasonTagsUpdated     = `TagsUpdated`
+	EventReasonTagsUpdateError = `TagsUpdateError`
+)
+
+// desiredTags merges spec.tags with the mirrored labels named in
+// spec.tagLabels and the owner and cluster tags. The result is sorted by tag
+// name.
+func desiredTags(server *bmcv1.Server, clusterID string) []tagAssignment {
+	merged := map[string]string{
+		ownerTagName:     createKey(server),
+		clusterTagName:   clusterID,
+		namespaceTagName: server.Namespace,
+	}
+	for _, key := range server.Spec.TagLabels {
+		if v, ok := server.Labels[key]; ok {
+			merged[key] = v
+		}
+	}
+	for k, v := range server.Spec.Tags {
+		merged[k] = v
+	}
+
+	tags := make([]tagAssignment, 0, len(merged))
+	for k, v := range merged {
+		tags = append(tags, tagAssignment{Name: k, Value: v})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags
+}
+
+// tagsInSync reports whether the tags observed on the BMC server match the
+// desired assignments.
+func tagsInSync(desired []tagAssignment, live []bmcv1.ServerTag) bool {
+	if len(desired) != len(live) {
+		return false
+	}
+	observed := map[string]string{}
+	for _, t := range live {
+		observed[t.Name] = t.Value
+	}
+	for _, t := range desired {
+		if v, ok := observed[t.Name]; !ok || v != t.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// syncTags overwrites the tags on the BMC server when they differ from the
+// desired assignments.
+func (r *ServerReconciler) syncTags(bmc *http.Client, server *bmcv1.Server, live []bmcv1.ServerTag) error {
+	desired := desiredTags(server, r.ClusterID)
+	if tagsInSync(desired, live) {
+		return nil
+	}
+	if err := ensureOwnerTags(context.Background()); err != nil {
+		return err
+	}
+
+	reqBody, err := json.Marshal(desired)
+	if err != nil {
+		return err
+	}
+	apiReq, err := http.NewRequest(
+		http.MethodPut,
+		fmt.Sprintf("%sservers/%s/tags", os.Getenv(ENV_BMC_ENDPOINT_URL), server.Annotations[bmcServerIDAnnotation]),
+		bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	apiReq.Header.Set(`Content-Type`, `application/json`)
+	apiResp, err := bmc.Do(apiReq)
+	if err != nil {
+		r.Recorder.Event(server, `Warning`, EventReasonTagsUpdateError, err.Error())
+		return err
+	}
+	defer apiResp.Body.Close()
+
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		r.Recorder.Event(server, `Warning`, EventReasonTagsUpdateError, err.Error())
+		return err
+	}
+
+	switch apiResp.StatusCode {
+	case 200:
+		var ss bmcv1.ServerStatus
+		if err := json.Unmarshal(body, &ss); err == nil {
+			server.Status.Tags = ss.Tags
+		}
+		r.Recorder.Eventf(server, `Normal`, EventReasonTagsUpdated, "Updated %d tags on BMC server", len(desired))
+		return nil
+	default:
+		r.Recorder.Eventf(server, `Warning`, EventReasonTagsUpdateError, `Code: %v`, apiResp.StatusCode)
+		return fmt.Errorf("unexpected response during server tag update: %v", apiResp.StatusCode)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+var (
+	// provisionedHashAnnotation records a hash of the spec fields the BMC
+	// server was last provisioned with.
+	provisionedHashAnnotation = `bmc.api.phoenixnap.com/provisioned_hash`
+
+	// replacedServerIDAnnotation records the ID of a BMC server that is being
+	// replaced and still has to be deleted.
+	replacedServerIDAnnotation = `bmc.api.phoenixnap.com/replaced_server_id`
+
+	EventReasonReplacing    = `Replacing`
+	EventReasonReplaceError = `ReplaceError`
+)
+
+// provisionedHash hashes the spec fields applied when the operating system is
+// installed.
+func provisionedHash(spec *bmcv1.ServerSpec) string {
+	b, _ := json.Marshal(struct {
+		Hostname              string
+		Description           string
+		OS                    bmcv1.ServerOS
+		InstallDefaultSSHKeys *bool
+		SSHKeyIDs             []string
+		UserDataSecretRef     *bmcv1.SecretKeyReference
+	}{
+		spec.Hostname,
+		spec.Description,
+		spec.OS,
+		spec.InstallDefaultSSHKeys,
+		spec.SSHKeyIDs,
+		spec.UserDataSecretRef,
+	})
+	h := fnv.New32a()
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum32())
+}
+
+// applyUpdate applies spec changes permitted by the update policy to a
+// powered on server. Type, location and network type changes replace the BMC
+// server, other changes reprovision it. It reports whether an update was
+// started.
+func (r *ServerReconciler) applyUpdate(ctx context.Context, bmc *http.Client, server *bmcv1.Server, record *serverRecord) (bool, error) {
+	hash := provisionedHash(&server.Spec)
+	provisioned, ok := server.Annotations[provisionedHashAnnotation]
+	if !ok {
+		// provisioned before changes were tracked
+		server.Annotations[provisionedHashAnnotation] = hash
+		return false, nil
+	}
+	if len(server.Spec.UpdatePolicy) == 0 || server.Status.BMCStatus != StatusPoweredOn {
+		return false, nil
+	}
+
+	if server.Spec.UpdatePolicy == bmcv1.UpdateRecreate && needsReplacement(server, record) {
+		// The replaced server is deleted by deleteReplacedServer once the new
+		// create key is saved. The new server is created under the new key so
+		// that the replaced server is not adopted.
+		bmcServerID := server.Annotations[bmcServerIDAnnotation]
+		r.Recorder.Eventf(server, `Normal`, EventReasonReplacing, "Replacing BMC server %s with a %s server in %s", bmcServerID, server.Spec.Type, server.Spec.Location)
+		server.Annotations[replacedServerIDAnnotation] = bmcServerID
+		delete(server.Annotations, bmcServerIDAnnotation)
+		delete(server.Annotations, provisionedHashAnnotation)
+		server.Annotations[createKeyAnnotation] = fmt.Sprintf("%s-%d", server.UID, server.Generation)
+		server.Status = observedStatus(server, bmcv1.ServerStatus{})
+		return true, nil
+	}
+
+	if provisioned == hash {
+		return false, nil
+	}
+	if err := r.reprovision(ctx, bmc, server); err != nil {
+		return false, err
+	}
+	return server.Annotations[provisionedHashAnnotation] == hash, nil
+}
+
+// deleteReplacedServer deletes the BMC server recorded by a replacement. It
+// reports whether the annotation was removed and the server must be saved.
+func (r *ServerReconciler) deleteReplacedServer(bmc *http.Client, server *bmcv1.Server) (bool, error) {
+	bmcServerID, ok := server.Annotations[replacedServerIDAnnotation]
+	if !ok {
+		return false, nil
+	}
+	keepIPBlocks := server.Spec.Network != nil && len(server.Spec.Network.IPBlocks) > 0
+	if err := deleteBMCServer(bmc, bmcServerID, keepIPBlocks); err != nil {
+		r.Recorder.Event(server, `Warning`, EventReasonReplaceError, err.Error())
+		return false, err
+	}
+	r.Recorder.Eventf(server, `Normal`, EventReasonReplacing, "Deleted replaced BMC server %s", bmcServerID)
+	delete(server.Annotations, replacedServerIDAnnotation)
+	return true, nil
+}
+
+// needsReplacement reports whether the BMC server no longer has an acceptable
+// type, location or network type.
+func needsReplacement(server *bmcv1.Server, record *serverRecord) bool {
+	if len(server.Spec.NetworkType) > 0 && len(record.NetworkType) > 0 && record.NetworkType != server.Spec.NetworkType {
+		return true
+	}
+	if len(record.Type) == 0 || len(record.Location) == 0 {
+		return false
+	}
+	for _, p := range placements(&server.Spec) {
+		if p.Type == record.Type && p.Location == record.Location {
+			return false
+		}
+	}
+	return true
+}
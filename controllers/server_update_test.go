@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+func TestProvisionedHash(t *testing.T) {
+	yes := true
+	base := bmcv1.ServerSpec{
+		Hostname: `web-1`,
+		OS:       bmcv1.UbuntuBionic,
+		Type:     bmcv1.S1C1Small,
+		Location: bmcv1.Phoenix,
+	}
+	tests := []struct {
+		name    string
+		mutate  func(*bmcv1.ServerSpec)
+		changed bool
+	}{
+		{`unchanged`, func(s *bmcv1.ServerSpec) {}, false},
+		{`hostname`, func(s *bmcv1.ServerSpec) { s.Hostname = `web-2` }, true},
+		{`description`, func(s *bmcv1.ServerSpec) { s.Description = `web` }, true},
+		{`os`, func(s *bmcv1.ServerSpec) { s.OS = bmcv1.CentosCentos7 }, true},
+		{`default ssh keys`, func(s *bmcv1.ServerSpec) { s.InstallDefaultSSHKeys = &yes }, true},
+		{`ssh keys`, func(s *bmcv1.ServerSpec) { s.SSHKeyIDs = []string{`5fa54d1e91867c03a0a7b4a4`} }, true},
+		{`user data`, func(s *bmcv1.ServerSpec) {
+			s.UserDataSecretRef = &bmcv1.SecretKeyReference{Name: `cloud-init`, Key: `user-data`}
+		}, true},
+		{`type`, func(s *bmcv1.ServerSpec) { s.Type = bmcv1.S1C1Medium }, false},
+		{`location`, func(s *bmcv1.ServerSpec) { s.Location = bmcv1.Ashburn }, false},
+		{`tags`, func(s *bmcv1.ServerSpec) { s.Tags = map[string]string{`team`: `web`} }, false},
+	}
+	want := provisionedHash(&base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := base
+			tt.mutate(&spec)
+			if got := provisionedHash(&spec); (got != want) != tt.changed {
+				t.Errorf("provisionedHash() = %s, base %s, want changed %v", got, want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestNeedsReplacement(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   bmcv1.ServerSpec
+		record serverRecord
+		want   bool
+	}{
+		{
+			name:   `same placement`,
+			spec:   bmcv1.ServerSpec{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix}},
+		},
+		{
+			name:   `type changed`,
+			spec:   bmcv1.ServerSpec{Type: bmcv1.S1C1Medium, Location: bmcv1.Phoenix},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix}},
+			want:   true,
+		},
+		{
+			name:   `location changed`,
+			spec:   bmcv1.ServerSpec{Type: bmcv1.S1C1Small, Location: bmcv1.Ashburn},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix}},
+			want:   true,
+		},
+		{
+			name: `placed on a type preference`,
+			spec: bmcv1.ServerSpec{
+				Type:            bmcv1.S1C1Small,
+				TypePreferences: []bmcv1.ServerType{bmcv1.S1C1Medium},
+				Location:        bmcv1.Phoenix,
+			},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Medium, Location: bmcv1.Phoenix}},
+		},
+		{
+			name: `placed on a location preference`,
+			spec: bmcv1.ServerSpec{
+				Type:                bmcv1.S1C1Small,
+				Location:            bmcv1.Phoenix,
+				LocationPreferences: []bmcv1.LocationID{bmcv1.Ashburn},
+			},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Ashburn}},
+		},
+		{
+			name:   `placement unknown`,
+			spec:   bmcv1.ServerSpec{Type: bmcv1.S1C1Medium, Location: bmcv1.Phoenix},
+			record: serverRecord{},
+		},
+		{
+			name:   `network type changed`,
+			spec:   bmcv1.ServerSpec{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix, NetworkType: bmcv1.PrivateOnly},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix}, NetworkType: bmcv1.PublicAndPrivate},
+			want:   true,
+		},
+		{
+			name:   `network type unset`,
+			spec:   bmcv1.ServerSpec{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix},
+			record: serverRecord{ServerStatus: bmcv1.ServerStatus{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix}, NetworkType: bmcv1.PublicAndPrivate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &bmcv1.Server{Spec: tt.spec}
+			if got := needsReplacement(server, &tt.record); got != tt.want {
+				t.Errorf("needsReplacement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// ServerClaimReconciler reconciles a ServerClaim object
+type ServerClaimReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverclaims/status,verbs=get;update;patch
+
+var (
+	serverClaimLabel = `bmc.api.phoenixnap.com/claimed-by`
+
+	EventReasonBound = `Bound`
+	EventReasonLost  = `Lost`
+)
+
+func (r *ServerClaimReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("serverclaim", req.NamespacedName)
+
+	// 1. get the ServerClaim
+	var claim bmcv1.ServerClaim
+	if err := r.Get(ctx, req.NamespacedName, &claim); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !claim.ObjectMeta.DeletionTimestamp.IsZero() {
+		// the bound server is removed by garbage collection
+		return ctrl.Result{}, nil
+	}
+
+	// 2. Already bound or reserved? Verify the server still exists and
+	// finish binding a reserved server
+	if len(claim.Status.ServerName) > 0 {
+		var server bmcv1.Server
+		err := r.Get(ctx, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Status.ServerName}, &server)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		switch {
+		case err == nil && metav1.IsControlledBy(&server, &claim):
+			return ctrl.Result{}, r.markBound(ctx, &claim)
+		case claim.Status.Phase == bmcv1.ClaimBound || claim.Status.Phase == bmcv1.ClaimLost:
+			if claim.Status.Phase != bmcv1.ClaimLost {
+				r.Recorder.Eventf(&claim, `Warning`, EventReasonLost, "Server %s no longer exists", claim.Status.ServerName)
+				claim.Status.Phase = bmcv1.ClaimLost
+				if err := r.Update(ctx, &claim); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return ctrl.Result{}, nil
+		case err == nil && isIdlePoolMember(&server):
+			return ctrl.Result{}, r.bind(ctx, &claim, &server)
+		default:
+			// the reserved server was deleted or bound by another claim
+			log.Info(`reserved server is no longer available`, `server`, claim.Status.ServerName)
+			claim.Status = bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending}
+			if err := r.Update(ctx, &claim); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	// 3. Find an idle, powered on pool member matching the claim
+	poolLabels := client.HasLabels{serverPoolLabel}
+	var servers bmcv1.ServerList
+	if err := r.List(ctx, &servers, client.InNamespace(claim.Namespace), poolLabels); err != nil {
+		return ctrl.Result{}, err
+	}
+	candidates, err := claimCandidates(&claim, servers.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(candidates) == 0 {
+		if claim.Status.Phase != bmcv1.ClaimPending {
+			claim.Status.Phase = bmcv1.ClaimPending
+			if err := r.Update(ctx, &claim); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		log.Info(`no matching server available`)
+		return requeueAfter1Min, nil
+	}
+
+	// 4. Reserve the oldest candidate on the claim before binding it, so that
+	// a failed bind is retried with the same server rather than another one.
+	server := candidates[0]
+	claim.Status = bmcv1.ServerClaimStatus{
+		Phase:      bmcv1.ClaimPending,
+		ServerName: server.Name,
+		PoolName:   metav1.GetControllerOf(&server).Name,
+	}
+	if err := r.Update(ctx, &claim); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.bind(ctx, &claim, &server)
+}
+
+// bind hands the controller reference of the reserved server over to the
+// claim. The update fails on conflict if another claim won the race.
+func (r *ServerClaimReconciler) bind(ctx context.Context, claim *bmcv1.ServerClaim, server *bmcv1.Server) error {
+	var refs []metav1.OwnerReference
+	for _, ref := range server.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			refs = append(refs, ref)
+		}
+	}
+	server.OwnerReferences = refs
+	if err := ctrl.SetControllerReference(claim, server, r.Scheme); err != nil {
+		return err
+	}
+	server.Labels[serverClaimLabel] = claim.Name
+	if err := r.Update(ctx, server); err != nil {
+		return err
+	}
+	r.Log.Info(`bound`, `serverclaim`, claim.Name, `server`, server.Name)
+	return r.markBound(ctx, claim)
+}
+
+// markBound records that the reserved server is bound to the claim.
+func (r *ServerClaimReconciler) markBound(ctx context.Context, claim *bmcv1.ServerClaim) error {
+	if claim.Status.Phase == bmcv1.ClaimBound {
+		return nil
+	}
+	r.Recorder.Eventf(claim, `Normal`, EventReasonBound, "Bound to server %s from pool %s", claim.Status.ServerName, claim.Status.PoolName)
+	claim.Status.Phase = bmcv1.ClaimBound
+	return r.Update(ctx, claim)
+}
+
+// claimCandidates returns the idle, powered on pool members that satisfy the
+// claim, oldest first.
+func claimCandidates(claim *bmcv1.ServerClaim, servers []bmcv1.Server) ([]bmcv1.Server, error) {
+	selector := labels.Everything()
+	if claim.Spec.Selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(claim.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		selector = s
+	}
+	var candidates []bmcv1.Server
+	for _, server := range servers {
+		if !isIdlePoolMember(&server) {
+			continue
+		}
+		if len(claim.Spec.PoolName) > 0 && metav1.GetControllerOf(&server).Name != claim.Spec.PoolName {
+			continue
+		}
+		if server.Status.BMCStatus != StatusPoweredOn ||
+			(len(claim.Spec.Type) > 0 && server.Status.Type != claim.Spec.Type) ||
+			(len(claim.Spec.Location) > 0 && server.Status.Location != claim.Spec.Location) ||
+			!selector.Matches(labels.Set(server.Labels)) {
+			continue
+		}
+		candidates = append(candidates, server)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].CreationTimestamp.Before(&candidates[j].CreationTimestamp)
+	})
+	return candidates, nil
+}
+
+// isIdlePoolMember reports whether the server is controlled by its pool and
+// not being deleted.
+func isIdlePoolMember(server *bmcv1.Server) bool {
+	owner := metav1.GetControllerOf(server)
+	return owner != nil && owner.Kind == `ServerPool` && server.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// pendingClaims maps a pool member to the unbound claims in its namespace so
+// that claims are retried as soon as a server becomes available.
+func (r *ServerClaimReconciler) pendingClaims(o handler.MapObject) []reconcile.Request {
+	if _, ok := o.Meta.GetLabels()[serverPoolLabel]; !ok {
+		return nil
+	}
+	var claims bmcv1.ServerClaimList
+	if err := r.List(context.Background(), &claims, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, claim := range claims.Items {
+		if len(claim.Status.ServerName) == 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}})
+		}
+	}
+	return requests
+}
+
+func (r *ServerClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.ServerClaim{}).
+		Owns(&bmcv1.Server{}).
+		Watches(&source.Kind{Type: &bmcv1.Server{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.pendingClaims),
+		}).
+		Complete(r)
+}
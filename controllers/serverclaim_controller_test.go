@@ -0,0 +1,252 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// poolServer returns a server controlled by a pool.
+func poolServer(name, pool string, age time.Duration, status string) bmcv1.Server {
+	yes := true
+	return bmcv1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         `default`,
+			UID:               types.UID(name + `-uid`),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			Labels:            map[string]string{serverPoolLabel: pool, `tier`: `web`},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: bmcv1.GroupVersion.String(),
+				Kind:       `ServerPool`,
+				Name:       pool,
+				UID:        types.UID(pool + `-uid`),
+				Controller: &yes,
+			}},
+		},
+		Status: bmcv1.ServerStatus{BMCStatus: status, Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix},
+	}
+}
+
+func TestClaimCandidates(t *testing.T) {
+	now := metav1.Now()
+	deleting := poolServer(`deleting`, `web`, 5*time.Hour, StatusPoweredOn)
+	deleting.DeletionTimestamp = &now
+	claimed := poolServer(`claimed`, `web`, 5*time.Hour, StatusPoweredOn)
+	claimed.OwnerReferences[0].Kind = `ServerClaim`
+	medium := poolServer(`medium`, `web`, 4*time.Hour, StatusPoweredOn)
+	medium.Status.Type = bmcv1.S1C1Medium
+	ashburn := poolServer(`ashburn`, `web`, 4*time.Hour, StatusPoweredOn)
+	ashburn.Status.Location = bmcv1.Ashburn
+	db := poolServer(`db`, `web`, 4*time.Hour, StatusPoweredOn)
+	db.Labels[`tier`] = `db`
+	servers := []bmcv1.Server{
+		poolServer(`new`, `web`, 1*time.Hour, StatusPoweredOn),
+		poolServer(`old`, `web`, 3*time.Hour, StatusPoweredOn),
+		poolServer(`other-pool`, `batch`, 2*time.Hour, StatusPoweredOn),
+		poolServer(`creating`, `web`, 6*time.Hour, `creating`),
+		deleting, claimed, medium, ashburn, db,
+	}
+	tests := []struct {
+		name    string
+		spec    bmcv1.ServerClaimSpec
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: `any idle powered on member, oldest first`,
+			want: []string{`medium`, `ashburn`, `db`, `old`, `other-pool`, `new`},
+		},
+		{
+			name: `pool`,
+			spec: bmcv1.ServerClaimSpec{PoolName: `batch`},
+			want: []string{`other-pool`},
+		},
+		{
+			name: `type and location`,
+			spec: bmcv1.ServerClaimSpec{Type: bmcv1.S1C1Small, Location: bmcv1.Phoenix, PoolName: `web`},
+			want: []string{`db`, `old`, `new`},
+		},
+		{
+			name: `selector`,
+			spec: bmcv1.ServerClaimSpec{PoolName: `web`, Selector: &metav1.LabelSelector{MatchLabels: map[string]string{`tier`: `db`}}},
+			want: []string{`db`},
+		},
+		{
+			name: `no match`,
+			spec: bmcv1.ServerClaimSpec{Location: bmcv1.Singapore},
+		},
+		{
+			name: `invalid selector`,
+			spec: bmcv1.ServerClaimSpec{Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: `tier`, Operator: `Near`},
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := &bmcv1.ServerClaim{Spec: tt.spec}
+			candidates, err := claimCandidates(claim, servers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("claimCandidates() error = %v, want error %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, s := range candidates {
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("claimCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newClaimReconciler(objs ...runtime.Object) *ServerClaimReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = bmcv1.AddToScheme(scheme)
+	return &ServerClaimReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme, objs...),
+		Recorder: record.NewFakeRecorder(10),
+		Log:      logf.NullLogger{},
+		Scheme:   scheme,
+	}
+}
+
+func TestServerClaimReconcile(t *testing.T) {
+	yes := true
+	claim := func(status bmcv1.ServerClaimStatus) *bmcv1.ServerClaim {
+		return &bmcv1.ServerClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: `app`, Namespace: `default`, UID: `app-uid`},
+			Spec:       bmcv1.ServerClaimSpec{PoolName: `web`},
+			Status:     status,
+		}
+	}
+	boundTo := func(pooled bmcv1.Server, claimName string) *bmcv1.Server {
+		server := pooled.DeepCopy()
+		server.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: bmcv1.GroupVersion.String(),
+			Kind:       `ServerClaim`,
+			Name:       claimName,
+			UID:        types.UID(claimName + `-uid`),
+			Controller: &yes,
+		}}
+		server.Labels[serverClaimLabel] = claimName
+		return server
+	}
+	old := poolServer(`old`, `web`, 3*time.Hour, StatusPoweredOn)
+	newer := poolServer(`new`, `web`, 1*time.Hour, StatusPoweredOn)
+
+	tests := []struct {
+		name       string
+		claim      *bmcv1.ServerClaim
+		servers    []runtime.Object
+		wantStatus bmcv1.ServerClaimStatus
+		wantBound  string
+	}{
+		{
+			name:       `reserves and binds the oldest candidate`,
+			claim:      claim(bmcv1.ServerClaimStatus{}),
+			servers:    []runtime.Object{newer.DeepCopy(), old.DeepCopy()},
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimBound, ServerName: `old`, PoolName: `web`},
+			wantBound:  `old`,
+		},
+		{
+			name:       `no candidate`,
+			claim:      claim(bmcv1.ServerClaimStatus{}),
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending},
+		},
+		{
+			name:       `finishes binding the reserved server`,
+			claim:      claim(bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending, ServerName: `new`, PoolName: `web`}),
+			servers:    []runtime.Object{old.DeepCopy(), newer.DeepCopy()},
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimBound, ServerName: `new`, PoolName: `web`},
+			wantBound:  `new`,
+		},
+		{
+			name:       `marks a server bound before the status was written`,
+			claim:      claim(bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending, ServerName: `old`, PoolName: `web`}),
+			servers:    []runtime.Object{boundTo(old, `app`)},
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimBound, ServerName: `old`, PoolName: `web`},
+			wantBound:  `old`,
+		},
+		{
+			name:       `reserved server taken by another claim`,
+			claim:      claim(bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending, ServerName: `old`, PoolName: `web`}),
+			servers:    []runtime.Object{boundTo(old, `other`)},
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending},
+		},
+		{
+			name:       `reserved server deleted`,
+			claim:      claim(bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending, ServerName: `gone`, PoolName: `web`}),
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimPending},
+		},
+		{
+			name:       `bound server deleted`,
+			claim:      claim(bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimBound, ServerName: `gone`, PoolName: `web`}),
+			wantStatus: bmcv1.ServerClaimStatus{Phase: bmcv1.ClaimLost, ServerName: `gone`, PoolName: `web`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newClaimReconciler(append(tt.servers, tt.claim)...)
+			key := types.NamespacedName{Namespace: `default`, Name: `app`}
+			if _, err := r.Reconcile(ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			var got bmcv1.ServerClaim
+			if err := r.Get(context.Background(), key, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Status, tt.wantStatus) {
+				t.Errorf("claim status = %+v, want %+v", got.Status, tt.wantStatus)
+			}
+			if len(tt.wantBound) == 0 {
+				return
+			}
+			var server bmcv1.Server
+			if err := r.Get(context.Background(), types.NamespacedName{Namespace: `default`, Name: tt.wantBound}, &server); err != nil {
+				t.Fatal(err)
+			}
+			if !metav1.IsControlledBy(&server, &got) {
+				t.Errorf("server %s is controlled by %v, want the claim", server.Name, metav1.GetControllerOf(&server))
+			}
+			if len(server.OwnerReferences) != 1 {
+				t.Errorf("server %s owner references = %v, want only the claim", server.Name, server.OwnerReferences)
+			}
+			if server.Labels[serverClaimLabel] != `app` {
+				t.Errorf("server %s claim label = %q, want app", server.Name, server.Labels[serverClaimLabel])
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// ServerPoolReconciler reconciles a ServerPool object
+type ServerPoolReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverpools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverpools/status,verbs=get;update;patch
+
+var (
+	serverPoolLabel = `bmc.api.phoenixnap.com/server-pool`
+
+	// serverPoolIndexAnnotation records the index a pool member was created
+	// for. Indices stay taken while the member exists, claimed or not.
+	serverPoolIndexAnnotation = `bmc.api.phoenixnap.com/server_pool_index`
+)
+
+func (r *ServerPoolReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("serverpool", req.NamespacedName)
+
+	// 1. get the ServerPool
+	var pool bmcv1.ServerPool
+	if err := r.Get(ctx, req.NamespacedName, &pool); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pool.ObjectMeta.DeletionTimestamp.IsZero() {
+		// idle servers are removed by garbage collection, claimed servers
+		// belong to their claims
+		return ctrl.Result{}, nil
+	}
+
+	// 2. Find idle and claimed members
+	var servers bmcv1.ServerList
+	if err := r.List(ctx, &servers, client.InNamespace(pool.Namespace), client.MatchingLabels{serverPoolLabel: pool.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	var idle []bmcv1.Server
+	var status bmcv1.ServerPoolStatus
+	used := map[int]bool{}
+	for _, server := range servers.Items {
+		if index, err := strconv.Atoi(server.Annotations[serverPoolIndexAnnotation]); err == nil {
+			used[index] = true
+		}
+		if !server.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if metav1.IsControlledBy(&server, &pool) {
+			idle = append(idle, server)
+		} else if len(server.Labels[serverClaimLabel]) > 0 {
+			status.Claimed++
+		}
+	}
+
+	// 3. Replenish or shrink the idle capacity
+	switch diff := int(pool.Spec.Size) - len(idle); {
+	case diff > 0:
+		// Members are named by index so that a create repeated from a stale
+		// cache fails with AlreadyExists instead of creating another server.
+		for index, created := 0, 0; created < diff; index++ {
+			if used[index] {
+				continue
+			}
+			server, err := r.newServer(&pool, index)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			log.Info(`creating server`, `name`, server.Name)
+			if err := r.Create(ctx, server); err != nil && !apierrors.IsAlreadyExists(err) {
+				r.Recorder.Eventf(&pool, `Warning`, EventReasonCreateError, "Unable to create server %s: %v", server.Name, err)
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(&pool, `Normal`, EventReasonScaledUp, "Created server %s", server.Name)
+			used[index] = true
+			created++
+			idle = append(idle, *server)
+		}
+	case diff < 0:
+		sortForDeletion(idle, bmcv1.DeleteNewest)
+		for i := range idle[:-diff] {
+			log.Info(`deleting server`, `name`, idle[i].Name)
+			if err := r.Delete(ctx, &idle[i]); client.IgnoreNotFound(err) != nil {
+				r.Recorder.Eventf(&pool, `Warning`, EventReasonCleanupError, "Unable to delete server %s: %v", idle[i].Name, err)
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(&pool, `Normal`, EventReasonScaledDown, "Deleted server %s", idle[i].Name)
+		}
+		idle = idle[-diff:]
+	}
+
+	// 4. Report capacity
+	status.Idle = int32(len(idle))
+	status.Ready = int32(countReady(idle))
+	if status != pool.Status {
+		pool.Status = status
+		if err := r.Update(ctx, &pool); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// newServer builds the idle pool member for the given index from the pool
+// template.
+func (r *ServerPoolReconciler) newServer(pool *bmcv1.ServerPool, index int) (*bmcv1.Server, error) {
+	server := &bmcv1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%d", pool.Name, index),
+			Namespace:   pool.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *pool.Spec.Template.Spec.DeepCopy(),
+	}
+	for k, v := range pool.Spec.Template.Labels {
+		server.Labels[k] = v
+	}
+	for k, v := range pool.Spec.Template.Annotations {
+		server.Annotations[k] = v
+	}
+	server.Labels[serverPoolLabel] = pool.Name
+	server.Annotations[serverPoolIndexAnnotation] = strconv.Itoa(index)
+
+	prefix := pool.Spec.Template.Spec.Hostname
+	if len(prefix) == 0 {
+		prefix = pool.Name
+	}
+	server.Spec.Hostname = fmt.Sprintf("%s-%d", prefix, index)
+
+	if err := ctrl.SetControllerReference(pool, server, r.Scheme); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+func (r *ServerPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.ServerPool{}).
+		Owns(&bmcv1.Server{}).
+		Complete(r)
+}
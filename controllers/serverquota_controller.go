@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// ServerQuotaReconciler reports the usage of a ServerQuota object. Quotas are
+// enforced by the Server admission webhook.
+type ServerQuotaReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverquotas,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serverquotas/status,verbs=get;update;patch
+
+func (r *ServerQuotaReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	_ = r.Log.WithValues("serverquota", req.NamespacedName)
+
+	// 1. get the ServerQuota
+	var quota bmcv1.ServerQuota
+	if err := r.Get(ctx, req.NamespacedName, &quota); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. Count the servers in the namespace
+	var servers bmcv1.ServerList
+	if err := r.List(ctx, &servers, client.InNamespace(quota.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 3. Report the usage
+	status := quota.Usage(servers.Items)
+	if reflect.DeepEqual(status, quota.Status) {
+		return ctrl.Result{}, nil
+	}
+	quota.Status = status
+	return ctrl.Result{}, r.Update(ctx, &quota)
+}
+
+func (r *ServerQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.ServerQuota{}).
+		Watches(&source.Kind{Type: &bmcv1.Server{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.serverQuotas),
+		}).
+		Complete(r)
+}
+
+// serverQuotas maps a server to the quotas of its namespace.
+func (r *ServerQuotaReconciler) serverQuotas(obj handler.MapObject) []ctrl.Request {
+	var quotas bmcv1.ServerQuotaList
+	if err := r.List(context.Background(), &quotas, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := make([]ctrl.Request, 0, len(quotas.Items))
+	for _, quota := range quotas.Items {
+		requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: quota.Namespace, Name: quota.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,350 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// ServerSetReconciler reconciles a ServerSet object
+type ServerSetReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serversets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=serversets/status,verbs=get;update;patch
+
+var (
+	serverSetLabel           = `bmc.api.phoenixnap.com/server-set`
+	serverSetIndexAnnotation = `bmc.api.phoenixnap.com/server_set_index`
+
+	serverSetTemplateHashLabel = `bmc.api.phoenixnap.com/template-hash`
+
+	hostnameIndexPlaceholder = `{index}`
+
+	EventReasonScaledUp   = `ScaledUp`
+	EventReasonScaledDown = `ScaledDown`
+
+	StatusPoweredOn = `powered-on`
+)
+
+func (r *ServerSetReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("serverset", req.NamespacedName)
+
+	// 1. get the ServerSet
+	var set bmcv1.ServerSet
+	if err := r.Get(ctx, req.NamespacedName, &set); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !set.ObjectMeta.DeletionTimestamp.IsZero() {
+		// owned servers are removed by garbage collection
+		return ctrl.Result{}, nil
+	}
+
+	// 2. Find the servers owned by this set
+	var servers bmcv1.ServerList
+	if err := r.List(ctx, &servers, client.InNamespace(set.Namespace), client.MatchingLabels{serverSetLabel: set.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	used := map[int]bool{}
+	var active []bmcv1.Server
+	for _, server := range servers.Items {
+		if !metav1.IsControlledBy(&server, &set) {
+			continue
+		}
+		used[serverSetIndex(&server)] = true
+		if server.ObjectMeta.DeletionTimestamp.IsZero() {
+			active = append(active, server)
+		}
+	}
+
+	// 3. Scale up or down, or step a rolling replacement
+	replicas := 1
+	if set.Spec.Replicas != nil {
+		replicas = int(*set.Spec.Replicas)
+	}
+	hash, err := templateHash(&set)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var current, outdated []bmcv1.Server
+	for _, server := range active {
+		if server.Labels[serverSetTemplateHashLabel] == hash {
+			current = append(current, server)
+		} else {
+			outdated = append(outdated, server)
+		}
+	}
+
+	if set.Spec.Strategy.Type == bmcv1.RollingReplaceStrategy && len(outdated) > 0 {
+		maxSurge, maxUnavailable, err := rollingReplaceLimits(&set, replicas)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info(`rolling replace`, `current`, len(current), `outdated`, len(outdated))
+
+		// provision replacements within the surge budget
+		if n := minInt(replicas-len(current), replicas+maxSurge-len(active)); n > 0 {
+			created, err := r.createServers(ctx, &set, hash, used, n)
+			current = append(current, created...)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		// remove outdated servers as long as enough servers stay powered on
+		budget := countReady(current) + countReady(outdated) - (replicas - maxUnavailable)
+		sortForDeletion(outdated, set.Spec.DeletionOrder)
+		var victims, kept []bmcv1.Server
+		for _, server := range outdated {
+			if server.Status.BMCStatus != StatusPoweredOn {
+				victims = append(victims, server)
+			} else if budget > 0 {
+				victims = append(victims, server)
+				budget--
+			} else {
+				kept = append(kept, server)
+			}
+		}
+		if err := r.deleteServers(ctx, &set, victims); err != nil {
+			return ctrl.Result{}, err
+		}
+		outdated = kept
+	} else {
+		switch diff := replicas - len(active); {
+		case diff > 0:
+			created, err := r.createServers(ctx, &set, hash, used, diff)
+			current = append(current, created...)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		case diff < 0:
+			sortForDeletion(active, set.Spec.DeletionOrder)
+			if err := r.deleteServers(ctx, &set, active[:-diff]); err != nil {
+				return ctrl.Result{}, err
+			}
+			current, outdated = nil, nil
+			for _, server := range active[-diff:] {
+				if server.Labels[serverSetTemplateHashLabel] == hash {
+					current = append(current, server)
+				} else {
+					outdated = append(outdated, server)
+				}
+			}
+		}
+	}
+
+	// 4. Report replicas
+	status := bmcv1.ServerSetStatus{
+		Replicas:        int32(len(current) + len(outdated)),
+		ReadyReplicas:   int32(countReady(current) + countReady(outdated)),
+		UpdatedReplicas: int32(len(current)),
+	}
+	if status != set.Status {
+		set.Status = status
+		if err := r.Update(ctx, &set); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// createServers creates n servers from the set template at the lowest unused
+// replica indices. It returns the servers that were created.
+func (r *ServerSetReconciler) createServers(ctx context.Context, set *bmcv1.ServerSet, hash string, used map[int]bool, n int) ([]bmcv1.Server, error) {
+	var created []bmcv1.Server
+	for index := 0; len(created) < n; index++ {
+		if used[index] {
+			continue
+		}
+		server, err := r.newServer(set, index)
+		if err != nil {
+			return created, err
+		}
+		server.Labels[serverSetTemplateHashLabel] = hash
+		r.Log.Info(`creating server`, `serverset`, set.Name, `name`, server.Name)
+		if err := r.Create(ctx, server); err != nil && !apierrors.IsAlreadyExists(err) {
+			r.Recorder.Eventf(set, `Warning`, EventReasonCreateError, "Unable to create server %s: %v", server.Name, err)
+			return created, err
+		}
+		r.Recorder.Eventf(set, `Normal`, EventReasonScaledUp, "Created server %s", server.Name)
+		used[index] = true
+		created = append(created, *server)
+	}
+	return created, nil
+}
+
+// deleteServers deletes the given servers. BMC cleanup is left to the server finalizer.
+func (r *ServerSetReconciler) deleteServers(ctx context.Context, set *bmcv1.ServerSet, servers []bmcv1.Server) error {
+	for i := range servers {
+		r.Log.Info(`deleting server`, `serverset`, set.Name, `name`, servers[i].Name)
+		if err := r.Delete(ctx, &servers[i]); client.IgnoreNotFound(err) != nil {
+			r.Recorder.Eventf(set, `Warning`, EventReasonCleanupError, "Unable to delete server %s: %v", servers[i].Name, err)
+			return err
+		}
+		r.Recorder.Eventf(set, `Normal`, EventReasonScaledDown, "Deleted server %s", servers[i].Name)
+	}
+	return nil
+}
+
+// newServer builds the server for the given replica index from the set template.
+func (r *ServerSetReconciler) newServer(set *bmcv1.ServerSet, index int) (*bmcv1.Server, error) {
+	server := &bmcv1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%d", set.Name, index),
+			Namespace:   set.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *set.Spec.Template.Spec.DeepCopy(),
+	}
+	for k, v := range set.Spec.Template.Labels {
+		server.Labels[k] = v
+	}
+	for k, v := range set.Spec.Template.Annotations {
+		server.Annotations[k] = v
+	}
+	server.Labels[serverSetLabel] = set.Name
+	server.Annotations[serverSetIndexAnnotation] = strconv.Itoa(index)
+
+	pattern := set.Spec.HostnamePattern
+	if len(pattern) == 0 {
+		pattern = set.Spec.Template.Spec.Hostname
+	}
+	if !strings.Contains(pattern, hostnameIndexPlaceholder) {
+		pattern = pattern + `-` + hostnameIndexPlaceholder
+	}
+	server.Spec.Hostname = strings.Replace(pattern, hostnameIndexPlaceholder, strconv.Itoa(index), -1)
+
+	if err := ctrl.SetControllerReference(set, server, r.Scheme); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// templateHash returns a short digest of the set template. Servers are
+// labeled with the digest of the template they were created from.
+func templateHash(set *bmcv1.ServerSet) (string, error) {
+	b, err := json.Marshal(set.Spec.Template)
+	if err != nil {
+		return ``, err
+	}
+	h := fnv.New32a()
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum32()), nil
+}
+
+// rollingReplaceLimits resolves maxSurge and maxUnavailable against the
+// desired number of replicas. At least one of them is always positive.
+func rollingReplaceLimits(set *bmcv1.ServerSet, replicas int) (int, int, error) {
+	maxSurge := intstr.FromInt(1)
+	maxUnavailable := intstr.FromInt(0)
+	if rr := set.Spec.Strategy.RollingReplace; rr != nil {
+		if rr.MaxSurge != nil {
+			maxSurge = *rr.MaxSurge
+		}
+		if rr.MaxUnavailable != nil {
+			maxUnavailable = *rr.MaxUnavailable
+		}
+	}
+	surge, err := intstr.GetValueFromIntOrPercent(&maxSurge, replicas, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	unavailable, err := intstr.GetValueFromIntOrPercent(&maxUnavailable, replicas, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if surge <= 0 && unavailable <= 0 {
+		surge = 1
+	}
+	return surge, unavailable, nil
+}
+
+func countReady(servers []bmcv1.Server) int {
+	ready := 0
+	for _, server := range servers {
+		if server.Status.BMCStatus == StatusPoweredOn {
+			ready++
+		}
+	}
+	return ready
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// serverSetIndex returns the replica index recorded on a server, or -1.
+func serverSetIndex(server *bmcv1.Server) int {
+	index, err := strconv.Atoi(server.Annotations[serverSetIndexAnnotation])
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// sortForDeletion orders servers so that the first ones are the preferred
+// candidates for removal. Servers that are not powered on always come first.
+func sortForDeletion(servers []bmcv1.Server, order bmcv1.DeletionOrder) {
+	sort.SliceStable(servers, func(i, j int) bool {
+		iReady := servers[i].Status.BMCStatus == StatusPoweredOn
+		jReady := servers[j].Status.BMCStatus == StatusPoweredOn
+		if iReady != jReady {
+			return !iReady
+		}
+		switch order {
+		case bmcv1.DeleteOldest:
+			return servers[i].CreationTimestamp.Before(&servers[j].CreationTimestamp)
+		case bmcv1.DeleteHighestIndex:
+			return serverSetIndex(&servers[i]) > serverSetIndex(&servers[j])
+		default:
+			return servers[j].CreationTimestamp.Before(&servers[i].CreationTimestamp)
+		}
+	})
+}
+
+func (r *ServerSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.ServerSet{}).
+		Owns(&bmcv1.Server{}).
+		Complete(r)
+}
@@ -0,0 +1,147 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRollingReplaceLimits(t *testing.T) {
+	intOrString := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	tests := []struct {
+		name            string
+		rollingReplace  *bmcv1.RollingReplaceServerSet
+		replicas        int
+		wantSurge       int
+		wantUnavailable int
+		wantErr         bool
+	}{
+		{
+			name:      `defaults`,
+			replicas:  4,
+			wantSurge: 1,
+		},
+		{
+			name: `absolute values`,
+			rollingReplace: &bmcv1.RollingReplaceServerSet{
+				MaxSurge:       intOrString(intstr.FromInt(2)),
+				MaxUnavailable: intOrString(intstr.FromInt(1)),
+			},
+			replicas:        4,
+			wantSurge:       2,
+			wantUnavailable: 1,
+		},
+		{
+			name: `percentages round surge up and unavailable down`,
+			rollingReplace: &bmcv1.RollingReplaceServerSet{
+				MaxSurge:       intOrString(intstr.FromString(`25%`)),
+				MaxUnavailable: intOrString(intstr.FromString(`25%`)),
+			},
+			replicas:        5,
+			wantSurge:       2,
+			wantUnavailable: 1,
+		},
+		{
+			name: `unavailable only`,
+			rollingReplace: &bmcv1.RollingReplaceServerSet{
+				MaxSurge:       intOrString(intstr.FromInt(0)),
+				MaxUnavailable: intOrString(intstr.FromInt(2)),
+			},
+			replicas:        4,
+			wantUnavailable: 2,
+		},
+		{
+			name: `both zero surges by one`,
+			rollingReplace: &bmcv1.RollingReplaceServerSet{
+				MaxSurge:       intOrString(intstr.FromInt(0)),
+				MaxUnavailable: intOrString(intstr.FromString(`10%`)),
+			},
+			replicas:  4,
+			wantSurge: 1,
+		},
+		{
+			name: `invalid percentage`,
+			rollingReplace: &bmcv1.RollingReplaceServerSet{
+				MaxSurge: intOrString(intstr.FromString(`many`)),
+			},
+			replicas: 4,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &bmcv1.ServerSet{}
+			set.Spec.Strategy.RollingReplace = tt.rollingReplace
+			surge, unavailable, err := rollingReplaceLimits(set, tt.replicas)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rollingReplaceLimits() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (surge != tt.wantSurge || unavailable != tt.wantUnavailable) {
+				t.Errorf("rollingReplaceLimits() = %d, %d, want %d, %d", surge, unavailable, tt.wantSurge, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
+func TestSortForDeletion(t *testing.T) {
+	now := time.Now()
+	server := func(name string, index int, age time.Duration, status string) bmcv1.Server {
+		return bmcv1.Server{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				Annotations:       map[string]string{serverSetIndexAnnotation: strconv.Itoa(index)},
+			},
+			Status: bmcv1.ServerStatus{BMCStatus: status},
+		}
+	}
+	servers := []bmcv1.Server{
+		server(`a`, 1, 3*time.Hour, StatusPoweredOn),
+		server(`b`, 0, 1*time.Hour, StatusPoweredOn),
+		server(`c`, 2, 2*time.Hour, StatusPoweredOn),
+		server(`d`, 3, 4*time.Hour, `creating`),
+	}
+	tests := []struct {
+		order bmcv1.DeletionOrder
+		want  []string
+	}{
+		{bmcv1.DeleteNewest, []string{`d`, `b`, `c`, `a`}},
+		{``, []string{`d`, `b`, `c`, `a`}},
+		{bmcv1.DeleteOldest, []string{`d`, `a`, `c`, `b`}},
+		{bmcv1.DeleteHighestIndex, []string{`d`, `c`, `a`, `b`}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			sorted := append([]bmcv1.Server(nil), servers...)
+			sortForDeletion(sorted, tt.order)
+			var got []string
+			for _, s := range sorted {
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortForDeletion(%q) = %v, want %v", tt.order, got, tt.want)
+			}
+		})
+	}
+}
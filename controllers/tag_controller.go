@@ -0,0 +1,254 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
+)
+
+// TagReconciler reconciles a Tag object
+type TagReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=tags,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bmc.api.phoenixnap.com,resources=tags/status,verbs=get;update;patch
+
+var (
+	bmcTagIDAnnotation = `bmc.api.phoenixnap.com/tag_id`
+
+	tagFinalizerName = `tag.finalizers.bmc.api.phoenixnap.com`
+
+	EventReasonUpdated     = `Updated`
+	EventReasonUpdateError = `UpdateError`
+
+	StatusSynced = `synced`
+)
+
+func (r *TagReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("tag", req.NamespacedName)
+
+	// 1. get the Tag
+	var tag bmcv1.Tag
+	if err := r.Get(ctx, req.NamespacedName, &tag); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. Load a BMC client
+	bmc := bmcClient(ctx, "tags", "tags.read")
+	endpoint := endpointURL(ENV_BMC_TAGS_ENDPOINT_URL, defaultTagsEndpointURL)
+
+	// 3. Check for deletion activity and finalizer
+	if tag.ObjectMeta.DeletionTimestamp.IsZero() {
+		found := false
+		for _, finalizer := range tag.ObjectMeta.Finalizers {
+			if finalizer == tagFinalizerName {
+				found = true
+			}
+		}
+		if !found {
+			log.Info(`attaching finalizer`)
+			tag.ObjectMeta.Finalizers = append(tag.ObjectMeta.Finalizers, tagFinalizerName)
+			if err := r.Update(ctx, &tag); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		log.Info(`finalizing`)
+
+		bmcTagID := tag.Annotations[bmcTagIDAnnotation]
+		if tag.Status.BMCStatus != StatusOrphaned && len(bmcTagID) > 0 {
+			apiReq, err := http.NewRequest(
+				http.MethodDelete,
+				fmt.Sprintf("%stags/%s", endpoint, bmcTagID),
+				nil)
+			if err != nil {
+				r.Recorder.Event(&tag, `Warning`, EventReasonCleanupError, err.Error())
+				return ctrl.Result{}, err
+			}
+			apiResp, err := bmc.Do(apiReq)
+			if err != nil {
+				r.Recorder.Event(&tag, `Warning`, EventReasonCleanupError, err.Error())
+				return ctrl.Result{}, err
+			}
+			defer apiResp.Body.Close()
+
+			body, err := ioutil.ReadAll(apiResp.Body)
+			if err != nil {
+				r.Recorder.Event(&tag, `Warning`, EventReasonCleanupError, err.Error())
+				return ctrl.Result{}, err
+			}
+
+			switch apiResp.StatusCode {
+			case 400, 401:
+				log.Info("unable to delete", `code`, apiResp.StatusCode, `body`, string(body))
+				tag.Status.BMCStatus = StatusIrreconcilable
+				if err := r.Update(ctx, &tag); err != nil {
+					return ctrl.Result{}, err
+				}
+				return requeueAfter2Min, nil
+			case 403, 404:
+				// unauthorized or already gone
+				log.Info("unable to delete", `code`, apiResp.StatusCode, `body`, string(body))
+				tag.Status.BMCStatus = StatusOrphaned
+				if err := r.Update(ctx, &tag); err != nil {
+					return ctrl.Result{}, err
+				}
+				return requeueAfter2Min, nil
+			case 500:
+				// temporarily unavailable, backoff and retry
+				log.Info(`BMC temporarily unavailable`, `body`, string(body))
+				return requeueAfter2Min, nil
+			case 200, 202, 204:
+				r.Recorder.Eventf(&tag, `Normal`, EventReasonCleanupSuccess, "Deleted BMC tag %s", bmcTagID)
+			default:
+				r.Recorder.Eventf(&tag, `Warning`, EventReasonCleanupError, "Unexpected response from API: %v", apiResp.StatusCode)
+				return requeueAfter2Min, fmt.Errorf("unexpected response during tag delete: %v", apiResp.StatusCode)
+			}
+		}
+
+		for i, finalizer := range tag.ObjectMeta.Finalizers {
+			if finalizer == tagFinalizerName {
+				tag.ObjectMeta.Finalizers[i] = tag.ObjectMeta.Finalizers[len(tag.ObjectMeta.Finalizers)-1]
+				tag.ObjectMeta.Finalizers = tag.ObjectMeta.Finalizers[:len(tag.ObjectMeta.Finalizers)-1]
+				break
+			}
+		}
+		if err := r.Update(ctx, &tag); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	desired := tagRequest{
+		Name:         tag.TagName(),
+		Description:  tag.Spec.Description,
+		IsBillingTag: tag.Spec.IsBillingTag,
+	}
+	reqBody, err := json.Marshal(desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 4. Create, poll, or update? Branch on the bmcTagID annotation
+	bmcTagID := tag.Annotations[bmcTagIDAnnotation]
+	var apiReq *http.Request
+	if len(bmcTagID) == 0 {
+		log.Info(`creating`)
+		apiReq, err = http.NewRequest(http.MethodPost, fmt.Sprintf("%stags", endpoint), bytes.NewBuffer(reqBody))
+	} else if tag.Status.Name != desired.Name ||
+		tag.Status.Description != desired.Description ||
+		tag.Status.IsBillingTag != desired.IsBillingTag {
+		log.Info(`updating`)
+		apiReq, err = http.NewRequest(http.MethodPatch, fmt.Sprintf("%stags/%s", endpoint, bmcTagID), bytes.NewBuffer(reqBody))
+	} else {
+		log.Info(`polling`)
+		apiReq, err = http.NewRequest(http.MethodGet, fmt.Sprintf("%stags/%s", endpoint, bmcTagID), nil)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	apiReq.Header.Set(`Content-Type`, `application/json`)
+
+	apiResp, err := bmc.Do(apiReq)
+	if err != nil {
+		return requeueAfter2Min, err
+	}
+	defer apiResp.Body.Close()
+
+	body, err := ioutil.ReadAll(apiResp.Body)
+	if err != nil {
+		return requeueAfter2Min, err
+	}
+
+	switch apiResp.StatusCode {
+	case 400, 401, 409:
+		// bad data, duplicate name, or bad credentials
+		r.Recorder.Eventf(&tag, `Warning`, EventReasonCreateErrorPermanent, `Code: %v`, apiResp.StatusCode)
+		log.Info("unable to reconcile", `code`, apiResp.StatusCode, `body`, string(body))
+		tag.Status.BMCStatus = StatusIrreconcilable
+		if err := r.Update(ctx, &tag); err != nil {
+			return ctrl.Result{}, err
+		}
+		return requeueAfter5Min, nil
+	case 403, 404:
+		r.Recorder.Event(&tag, `Warning`, EventReasonResourceOrphaned, `Access to BMC resource was denied`)
+		log.Info("unable to reconcile", `code`, apiResp.StatusCode, `body`, string(body))
+		tag.Status.BMCStatus = StatusOrphaned
+		if err := r.Update(ctx, &tag); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	case 500:
+		log.Info(`BMC temporarily unavailable`, `body`, string(body))
+		tag.Status.BMCStatus = StatusStale
+		if err := r.Update(ctx, &tag); err != nil {
+			return ctrl.Result{}, err
+		}
+		return requeueAfter5Min, nil
+	case 200, 201:
+	default:
+		r.Recorder.Eventf(&tag, `Warning`, EventReasonPollFailure, `Unexpected response from API: %v`, apiResp.StatusCode)
+		return ctrl.Result{}, fmt.Errorf("unexpected response during tag reconcile: %v", apiResp.StatusCode)
+	}
+
+	var ss bmcv1.TagStatus
+	if err := json.Unmarshal(body, &ss); err != nil {
+		return requeueAfter2Min, err
+	}
+	ss.BMCStatus = StatusSynced
+
+	switch apiReq.Method {
+	case http.MethodPost:
+		r.Recorder.Eventf(&tag, `Normal`, EventReasonCreated, "created BMC tag %s", ss.BMCTagID)
+		if tag.Annotations == nil {
+			tag.Annotations = map[string]string{}
+		}
+		tag.Annotations[bmcTagIDAnnotation] = ss.BMCTagID
+	case http.MethodPatch:
+		r.Recorder.Eventf(&tag, `Normal`, EventReasonUpdated, "updated BMC tag %s", bmcTagID)
+	}
+
+	tag.Status = ss
+	if err := r.Update(ctx, &tag); err != nil {
+		return requeueAfter2Min, err
+	}
+	return requeueAfter5Min, nil
+}
+
+func (r *TagReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bmcv1.Tag{}).
+		Complete(r)
+}
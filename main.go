@@ -17,15 +17,20 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	bmcv1 "github.com/phoenixnap/k8s-bmc/api/v1"
 	"github.com/phoenixnap/k8s-bmc/controllers"
@@ -47,10 +52,35 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableNodeLifecycle bool
+	var catalogRefreshInterval time.Duration
+	var orphanSweepInterval time.Duration
+	var orphanGracePeriod time.Duration
+	var deleteOrphans bool
+	var expiryWarnings string
+	var priceTable string
+	var clusterID string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableNodeLifecycle, "enable-node-lifecycle", false,
+		"Enable the node lifecycle controller. "+
+			"Nodes running on BMC servers are initialized from the BMC server record and deleted when the server no longer exists.")
+	flag.DurationVar(&catalogRefreshInterval, "catalog-refresh-interval", time.Hour,
+		"How often the BMC product catalog used to validate server types and locations is refreshed.")
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", 10*time.Minute,
+		"How often BMC servers created by this controller are checked for a missing Server resource. Zero disables the sweep.")
+	flag.DurationVar(&orphanGracePeriod, "orphan-grace-period", time.Hour,
+		"How long a BMC server must be orphaned before it is reported or deleted.")
+	flag.BoolVar(&deleteOrphans, "delete-orphans", false,
+		"Delete orphaned BMC servers after the grace period instead of only reporting them.")
+	flag.StringVar(&expiryWarnings, "expiry-warnings", "24h,1h",
+		"Comma separated lead times before a server expires at which a warning event is emitted.")
+	flag.StringVar(&priceTable, "price-table", "",
+		"Path to a JSON file mapping type/location/pricingModel to hourly rates in USD. Overrides prices from the BMC products API.")
+	flag.StringVar(&clusterID, "cluster-id", "",
+		"ID written to the cluster tag of BMC servers created by this manager. Defaults to the UID of the kube-system namespace.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -76,20 +106,183 @@ func main() {
 		os.Exit(1)
 	}
 
+	expiryWarningLeadTimes := []time.Duration{}
+	for _, v := range strings.Split(expiryWarnings, ",") {
+		if v = strings.TrimSpace(v); len(v) == 0 {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			setupLog.Error(err, "invalid expiry warning lead time", "value", v)
+			os.Exit(1)
+		}
+		expiryWarningLeadTimes = append(expiryWarningLeadTimes, d)
+	}
+
+	var prices controllers.PriceTable
+	if len(priceTable) > 0 {
+		if prices, err = controllers.LoadPriceTable(priceTable); err != nil {
+			setupLog.Error(err, "unable to load price table", "path", priceTable)
+			os.Exit(1)
+		}
+	}
+
+	if len(clusterID) == 0 {
+		if clusterID, err = controllers.DefaultClusterID(context.Background(), mgr.GetAPIReader()); err != nil {
+			setupLog.Error(err, "unable to determine cluster ID, set --cluster-id")
+			os.Exit(1)
+		}
+	}
+
+	catalog := controllers.NewBMCCatalog(catalogRefreshInterval)
+	bmcv1.Catalog = catalog
+
 	if err = (&controllers.ServerReconciler{
+		Client:         mgr.GetClient(),
+		Recorder:       mgr.GetEventRecorderFor(`server-controller`),
+		Log:            ctrl.Log.WithName("controllers").WithName("Server"),
+		Scheme:         mgr.GetScheme(),
+		KubeClient:     kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		Catalog:        catalog,
+		ExpiryWarnings: expiryWarningLeadTimes,
+		Prices:         prices,
+		ClusterID:      clusterID,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Server")
+		os.Exit(1)
+	}
+	if err = (&controllers.NodeReconciler{
 		Client:   mgr.GetClient(),
-		Recorder: mgr.GetEventRecorderFor(`server-controller`),
-		Log:      ctrl.Log.WithName("controllers").WithName("Server"),
+		Recorder: mgr.GetEventRecorderFor(`node-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("Node"),
 		Scheme:   mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Server")
+		setupLog.Error(err, "unable to create controller", "controller", "Node")
+		os.Exit(1)
+	}
+	if err = (&controllers.IPBlockReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`ipblock-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("IPBlock"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "IPBlock")
+		os.Exit(1)
+	}
+	if err = (&controllers.TagReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`tag-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("Tag"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Tag")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServerSetReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`serverset-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("ServerSet"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerSet")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServerPoolReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`serverpool-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("ServerPool"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerPool")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServerClaimReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`serverclaim-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("ServerClaim"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerClaim")
 		os.Exit(1)
 	}
+	if err = (&controllers.BMCClusterReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`bmccluster-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("BMCCluster"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BMCCluster")
+		os.Exit(1)
+	}
+	if err = (&controllers.BMCMachineReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`bmcmachine-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("BMCMachine"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BMCMachine")
+		os.Exit(1)
+	}
+	if err = (&controllers.BMCBudgetReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(`bmcbudget-controller`),
+		Log:      ctrl.Log.WithName("controllers").WithName("BMCBudget"),
+		Scheme:   mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BMCBudget")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServerQuotaReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("ServerQuota"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerQuota")
+		os.Exit(1)
+	}
+	metrics.Registry.MustRegister(&controllers.CostCollector{Reader: mgr.GetClient()})
+	if enableNodeLifecycle {
+		if err = (&controllers.NodeLifecycleReconciler{
+			Client:   mgr.GetClient(),
+			Recorder: mgr.GetEventRecorderFor(`node-lifecycle-controller`),
+			Log:      ctrl.Log.WithName("controllers").WithName("NodeLifecycle"),
+			Scheme:   mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NodeLifecycle")
+			os.Exit(1)
+		}
+	}
+	if orphanSweepInterval > 0 {
+		if err = mgr.Add(&controllers.OrphanSweeper{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("controllers").WithName("OrphanSweeper"),
+			Recorder:    mgr.GetEventRecorderFor(`orphan-sweeper`),
+			ClusterID:   clusterID,
+			Interval:    orphanSweepInterval,
+			GracePeriod: orphanGracePeriod,
+			Delete:      deleteOrphans,
+		}); err != nil {
+			setupLog.Error(err, "unable to create sweeper", "sweeper", "OrphanSweeper")
+			os.Exit(1)
+		}
+	}
 	if os.Getenv(`ENABLE_WEBHOOKS`) != `false` {
 		if err = (&bmcv1.Server{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Server")
 			os.Exit(1)
 		}
+		if err = (&bmcv1.IPBlock{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "IPBlock")
+			os.Exit(1)
+		}
+		if err = (&bmcv1.BMCMachine{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "BMCMachine")
+			os.Exit(1)
+		}
+		if err = (&bmcv1.ServerClaim{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ServerClaim")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 